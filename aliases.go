@@ -0,0 +1,79 @@
+package pg_oui
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadAliases reads a vendor alias file for BuildOptions.Aliases and
+// WithAliases: "variant,canonical" CSV lines such as
+// "Intel Corporate,Intel". Lines starting with # are comments.
+func ReadAliases(r io.Reader) (map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	m := make(map[string]string)
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		variant, canonical := strings.TrimSpace(rec[0]), strings.TrimSpace(rec[1])
+		if variant == "" || canonical == "" {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: empty vendor name", line)
+		}
+		m[variant] = canonical
+	}
+}
+
+// WithAliases sets the vendor aliases used by CanonicalVendor, as read by
+// ReadAliases.
+func WithAliases(m map[string]string) Option { return func(c *openCfg) { c.aliases = m } }
+
+// aliasKey is what alias variants are matched on: the simplified name
+// without case, punctuation or spaces, so "INTEL CORP" and "Intel
+// Corporation" are the same variant.
+func aliasKey(name string) string { return fold(simplifyName(name)) }
+
+// newAliasTable keys the variant -> canonical map m by aliasKey. Canonical
+// names are variants of themselves.
+func newAliasTable(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	t := make(map[string]string, 2*len(m))
+	for _, c := range m {
+		t[aliasKey(c)] = c
+	}
+	for v, c := range m {
+		t[aliasKey(v)] = c
+	}
+	return t
+}
+
+// CanonicalVendor returns the canonical form of a vendor name, e.g. one
+// from another tool: its alias from WithAliases, else the dataset's vendor
+// name that differs from it only in case, punctuation or a legal suffix
+// such as Inc or Corp, else name itself. It scans the dataset's vendors.
+func (db *DB) CanonicalVendor(name string) string {
+	name = strings.TrimSpace(name)
+	key := aliasKey(name)
+	if c, ok := db.aliases[key]; ok {
+		return c
+	}
+	d := db.cur.Load()
+	for id := 0; id+1 < len(d.offsets); id++ {
+		if v, err := d.vendorByID(id); err == nil && fold(v) == key {
+			return v
+		}
+	}
+	return name
+}
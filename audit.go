@@ -0,0 +1,87 @@
+package pg_oui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// UpdateLogName is the append-only log of dataset updates kept in the data dir.
+const UpdateLogName = "updates.log"
+
+// UpdateRecord describes one dataset update. Records are stored one JSON
+// object per line in UpdateLogName.
+type UpdateRecord struct {
+	Time        time.Time `json:"time"`
+	Trigger     string    `json:"trigger"`        // e.g. "update_data", "auto-update"
+	Source      string    `json:"source"`         // URL or file the registry data came from
+	SHA256      string    `json:"sha256"`         // checksum of the source data
+	PreEntries  int       `json:"pre_entries"`    // entries before the update (0 if none)
+	PostEntries int       `json:"post_entries"`   // entries after the update
+	Host        string    `json:"host,omitempty"` // machine that ran the update
+	User        string    `json:"user,omitempty"` // OS user that ran the update
+}
+
+// AppendUpdateRecord appends rec to the update log in dir. Time, Host and
+// User are filled in when empty.
+func AppendUpdateRecord(dir string, rec UpdateRecord) error {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now().UTC()
+	}
+	if rec.Host == "" {
+		rec.Host, _ = os.Hostname()
+	}
+	if rec.User == "" {
+		if u, err := user.Current(); err == nil {
+			rec.User = u.Username
+		}
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode update record: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, UpdateLogName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("open update log: %w", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("write update log: %w", err)
+	}
+	return f.Close()
+}
+
+// ReadUpdateHistory returns the update records logged in dir, oldest first.
+// An empty dir uses the default data dir. A missing log yields no records.
+func ReadUpdateHistory(dir string) ([]UpdateRecord, error) {
+	if dir == "" {
+		dir = defaultDataDir()
+	}
+	b, err := os.ReadFile(filepath.Join(dir, UpdateLogName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read update log: %w", err)
+	}
+	var recs []UpdateRecord
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var rec UpdateRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("parse update log line %d: %w", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, sc.Err()
+}
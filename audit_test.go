@@ -0,0 +1,85 @@
+package pg_oui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateHistoryAppendAndRead(t *testing.T) {
+	dir := t.TempDir()
+
+	if recs, err := ReadUpdateHistory(dir); err != nil || len(recs) != 0 {
+		t.Fatalf("want empty history for new dir, got %v, err=%v", recs, err)
+	}
+
+	for _, rec := range []UpdateRecord{
+		{Trigger: "update_data", Source: "oui.csv", SHA256: "aa", PostEntries: 10},
+		{Trigger: "auto-update", Source: "oui.csv", SHA256: "bb", PreEntries: 10, PostEntries: 12},
+	} {
+		if err := AppendUpdateRecord(dir, rec); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	recs, err := ReadUpdateHistory(dir)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("want 2 records, got %d", len(recs))
+	}
+	if recs[0].Trigger != "update_data" || recs[1].PreEntries != 10 || recs[1].PostEntries != 12 {
+		t.Fatalf("unexpected records: %+v", recs)
+	}
+	if recs[0].Time.IsZero() {
+		t.Fatalf("want time filled in, got zero")
+	}
+}
+
+func TestSourceCache_Conditional(t *testing.T) {
+	mod := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "oui.csv", mod, strings.NewReader(testCSV))
+	}))
+	defer srv.Close()
+
+	get := func(c SourceCache) int {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		c.Condition(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			c.Record(srv.URL, resp)
+		}
+		return resp.StatusCode
+	}
+
+	dir := t.TempDir()
+	c, err := ReadSourceCache(dir)
+	if err != nil {
+		t.Fatalf("read empty cache: %v", err)
+	}
+	if code := get(c); code != http.StatusOK {
+		t.Fatalf("first download: status %d", code)
+	}
+	if err := c.Write(dir); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	c, err = ReadSourceCache(dir)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got := c[srv.URL]; got.ETag != `"v1"` || got.LastModified == "" {
+		t.Fatalf("validators = %+v", got)
+	}
+	if code := get(c); code != http.StatusNotModified {
+		t.Fatalf("conditional download: status %d, want 304", code)
+	}
+}
@@ -3,248 +3,226 @@
 package pg_oui
 
 import (
-	"bufio"
 	"bytes"
-	"encoding/binary"
-	"encoding/csv"
-	"errors"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/fs"
+	"math/rand/v2"
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 )
 
-const ouiURL = "https://standards-oui.ieee.org/oui/oui.csv"
+// fetchMarker records the time of the last download attempt in the data dir.
+const fetchMarker = ".last-fetch"
 
-func resolveOrBuild(cfg *openCfg) (fs.FS, error) {
-	if cfg.fsys != nil {
-		if f, err := cfg.fsys.Open(cfg.entriesName); err == nil {
-			f.Close()
-			return cfg.fsys, nil
-		}
-	}
+func resolveOrBuild(ctx context.Context, cfg *openCfg) (fs.FS, error) {
 	dir := cfg.dir
 	if dir == "" {
-		if env := os.Getenv("PG_OUI_DATA_DIR"); env != "" {
-			dir = env
-		} else if cdir, err := os.UserCacheDir(); err == nil {
-			dir = filepath.Join(cdir, "pg-oui")
-		} else {
-			dir = "."
+		dir = defaultDataDir()
+	}
+	if cfg.autoUpdate && (cfg.fsys == nil || cfg.dir != "") {
+		if err := migrateLegacy(dir, cfg); err != nil {
+			return nil, err
 		}
 	}
-	if exists(filepath.Join(dir, cfg.entriesName)) && exists(filepath.Join(dir, cfg.vendorsName)) && exists(filepath.Join(dir, cfg.indexName)) {
-		return os.DirFS(dir), nil
+	if cfg.fsys != nil && hasDataset(cfg.fsys, cfg) {
+		return cfg.fsys, nil
+	}
+	if fsys, ok := dataDirFS(dir, cfg); ok {
+		return fsys, nil
 	}
 	if !cfg.autoUpdate {
-		return nil, fmt.Errorf("dataset not found and auto-update disabled (dir=%s)", dir)
+		return nil, fmt.Errorf("%w and auto-update disabled (dir=%s)", ErrDatasetNotFound, dir)
 	}
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return nil, fmt.Errorf("create data dir: %w", err)
 	}
-	cl := defaultClient(cfg)
-	resp, err := cl.Get(ouiURL)
-	if err != nil {
-		return nil, fmt.Errorf("download OUI CSV: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("download OUI CSV: status %d", resp.StatusCode)
+	marker := filepath.Join(dir, fetchMarker)
+	if cfg.minInterval > 0 {
+		if st, err := os.Stat(marker); err == nil && time.Since(st.ModTime()) < cfg.minInterval {
+			return nil, fmt.Errorf("%w and last download attempt was %s ago (min interval %s)", ErrDatasetNotFound, time.Since(st.ModTime()).Round(time.Second), cfg.minInterval)
+		}
 	}
-	if err := buildFromCSV(resp.Body, dir, cfg.filter); err != nil {
-		return nil, fmt.Errorf("build dataset: %w", err)
+	var delay time.Duration
+	wait := cfg.jitter > 0
+	if wait {
+		delay = jitterN(cfg.jitter)
 	}
-	return os.DirFS(dir), nil
-}
-
-func defaultClient(cfg *openCfg) *http.Client {
-	if cfg.httpClient != nil {
-		if cl, ok := cfg.httpClient.(*http.Client); ok {
-			return cl
+	if cfg.schedulerURL != "" {
+		if d, err := updateSlot(ctx, defaultClient(cfg), cfg.schedulerURL); err == nil {
+			delay, wait = d, true
 		}
 	}
-	return &http.Client{Timeout: 30 * time.Second}
-}
-
-func exists(path string) bool {
-	st, err := os.Stat(path)
-	return err == nil && !st.IsDir()
-}
-
-var (
-	llcRegex  = regexp.MustCompile(`(?i),?\s*(llc|ltd|limited|inc|incorporated)\.?$`)
-	coRegex   = regexp.MustCompile(`(?i),?\s*(co|company|corp|corporation)\.?$`)
-	gmbhRegex = regexp.MustCompile(`(?i),?\s*gmbh\.?$`)
-)
-
-func simplifyName(name string) string {
-	b := []byte(name)
-	b = llcRegex.ReplaceAll(b, []byte{})
-	b = coRegex.ReplaceAll(b, []byte{})
-	b = gmbhRegex.ReplaceAll(b, []byte{})
-	return strings.TrimSpace(string(b))
-}
-
-type entry struct {
-	OUI      string
-	VendorID int
-	Vendor   string
-}
-
-func buildFromCSV(r io.Reader, outdir string, filt *Filter) error {
-	c := csv.NewReader(r)
-	if _, err := c.Read(); err != nil { // header
-		if !errors.Is(err, io.EOF) {
-			return fmt.Errorf("read header: %w", err)
-		}
-		return fmt.Errorf("empty CSV")
-	}
-	vendorSet := map[string]struct{}{}
-	ouiSet := map[string]struct{}{}
-	if filt != nil {
-		for _, v := range filt.VendorNames {
-			v = simplifyName(strings.TrimSpace(v))
-			if v != "" {
-				vendorSet[v] = struct{}{}
-			}
+	if wait {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
-		for _, o := range filt.OUIs {
-			o = strings.ToLower(strings.TrimSpace(o))
-			o = macCleaner.Replace(o)
-			if len(o) >= 6 {
-				ouiSet[o[:6]] = struct{}{}
-			}
+		// Another process sharing the dir may have built it meanwhile.
+		if fsys, ok := dataDirFS(dir, cfg); ok {
+			return fsys, nil
 		}
 	}
-	allowVendor := func(v string) bool {
-		if filt == nil {
-			return true
-		}
-		sv := simplifyName(v)
-		if len(vendorSet) > 0 {
-			if _, ok := vendorSet[sv]; !ok {
-				return false
-			}
-		}
-		if filt.VendorRegex != nil && !filt.VendorRegex.MatchString(sv) {
-			return false
-		}
-		return true
+	if err := os.WriteFile(marker, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0o644); err != nil {
+		return nil, fmt.Errorf("write fetch marker: %w", err)
 	}
-	allowOUI := func(o string) bool {
-		if filt == nil || len(ouiSet) == 0 {
-			return true
-		}
-		_, ok := ouiSet[strings.ToLower(o)]
-		return ok
-	}
-	ouiMap := make(map[string]string)
-	vendorMap := make(map[string]int)
-	var entries []entry
-	var vendors []string
-	for id := 0; ; {
-		rec, err := c.Read()
-		if errors.Is(err, io.EOF) {
-			break
-		}
+	names := cfg.registries
+	if len(names) == 0 && cfg.build != nil {
+		names = cfg.build.Registries
+	}
+	var source string
+	if cfg.build != nil {
+		source = cfg.build.Source
+	}
+	urls, err := SourceURLs(source, names)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.sourceURL != "":
+		urls = []string{cfg.sourceURL}
+	case cfg.downloadURL != "":
+		urls = mirrorURLs(cfg.downloadURL, urls)
+	}
+	cl := defaultClient(cfg)
+	cache, err := ReadSourceCache(dir)
+	if err != nil {
+		cache = SourceCache{}
+	}
+	// Only a dir that had a dataset can have one matching the validators.
+	_, err = os.Lstat(filepath.Join(dir, CurrentLink))
+	conditional := err == nil
+	bodies := make([][]byte, len(urls))
+	var unchanged []int
+	for i, u := range urls {
+		b, changed, err := fetch(ctx, cl, u, cache, conditional)
 		if err != nil {
-			return fmt.Errorf("read row: %w", err)
+			return nil, fmt.Errorf("%w: %s: %w", ErrDownloadFailed, u, err)
 		}
-		if len(rec) < 3 {
-			continue
+		if !changed {
+			unchanged = append(unchanged, i)
 		}
-		o := strings.ToLower(strings.TrimSpace(rec[1]))
-		if !allowOUI(o) {
-			continue
-		}
-		v := strings.ReplaceAll(strings.TrimSpace(rec[2]), `"`, "")
-		if !allowVendor(v) {
-			continue
-		}
-		v = simplifyName(v)
-		if _, ok := ouiMap[o]; ok {
-			continue
+		bodies[i] = b
+	}
+	if len(unchanged) == len(urls) {
+		// Nothing changed upstream: keep the dataset if it is usable, e.g.
+		// because another process rebuilt it meanwhile.
+		if fsys, ok := dataDirFS(dir, cfg); ok {
+			return fsys, nil
 		}
-		ouiMap[o] = v
-		if _, ok := vendorMap[v]; !ok {
-			vendors = append(vendors, v)
-			vendorMap[v] = id
-			id++
+	}
+	for _, i := range unchanged {
+		if bodies[i], _, err = fetch(ctx, cl, urls[i], cache, false); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrDownloadFailed, urls[i], err)
 		}
-		entries = append(entries, entry{OUI: o, Vendor: v, VendorID: vendorMap[v]})
-	}
-	sort.Slice(entries, func(i, j int) bool {
-		ai, _ := strconv.ParseInt(entries[i].OUI, 16, 64)
-		aj, _ := strconv.ParseInt(entries[j].OUI, 16, 64)
-		return ai < aj
-	})
-	if err := os.MkdirAll(outdir, 0o755); err != nil {
-		return fmt.Errorf("mkdir outdir: %w", err)
-	}
-	// Write entries
-	ef, err := os.Create(filepath.Join(outdir, defaultEntries))
-	if err != nil {
-		return fmt.Errorf("create entries: %w", err)
 	}
-	var buf bytes.Buffer
-	for _, e := range entries {
-		fmt.Fprintf(&buf, "%s,%d\n", e.OUI, e.VendorID)
+	var csvs []io.Reader
+	for _, b := range bodies {
+		csvs = append(csvs, bytes.NewReader(b), strings.NewReader("\n"))
+	}
+	pre := countEntries(filepath.Join(dir, CurrentLink, defaultEntries))
+	tmp, err := os.MkdirTemp(dir, ".build-")
+	if err != nil {
+		return nil, fmt.Errorf("build dataset: %w", err)
 	}
-	if _, err := ef.Write(buf.Bytes()); err != nil {
-		return fmt.Errorf("write entries: %w", err)
+	defer os.RemoveAll(tmp)
+	var opts BuildOptions
+	if cfg.build != nil {
+		opts = *cfg.build
 	}
-	_ = ef.Close()
-	// Write vendors
-	vf, err := os.Create(filepath.Join(outdir, defaultVendors))
+	opts.Origin = strings.Join(urls, " ")
+	opts.Attribution = SourceAttribution(source, names, urls)
+	h := sha256.New()
+	res, err := Build(io.TeeReader(io.MultiReader(csvs...), h), tmp, &opts)
 	if err != nil {
-		return fmt.Errorf("create vendors: %w", err)
+		return nil, fmt.Errorf("build dataset: %w", err)
 	}
-	buf.Reset()
-	for _, v := range vendors {
-		fmt.Fprintf(&buf, "%s\n", v)
+	if _, err := StoreDataset(dir, tmp); err != nil {
+		return nil, err
 	}
-	if _, err := vf.Write(buf.Bytes()); err != nil {
-		return fmt.Errorf("write vendors: %w", err)
+	rec := UpdateRecord{Trigger: "auto-update", Source: strings.Join(urls, " "), SHA256: hex.EncodeToString(h.Sum(nil)), PreEntries: pre, PostEntries: res.Entries}
+	if err := AppendUpdateRecord(dir, rec); err != nil {
+		return nil, fmt.Errorf("record update: %w", err)
 	}
-	_ = vf.Close()
-	// Create index
-	if err := createIndex(filepath.Join(outdir, defaultVendors)); err != nil {
-		return fmt.Errorf("create index: %w", err)
+	// Lets update_data -outdir dir skip the next refresh if nothing changed.
+	if err := cache.Write(dir); err != nil {
+		return nil, err
 	}
-	return nil
+	return os.DirFS(filepath.Join(dir, CurrentLink)), nil
 }
 
-func createIndex(dataFile string) error {
-	file, err := os.Open(dataFile)
+// jitterN picks the WithUpdateJitter delay; tests replace it.
+var jitterN = rand.N[time.Duration]
+
+// updateSlot asks the WithUpdateScheduler endpoint at url how long to wait
+// before downloading.
+func updateSlot(ctx context.Context, cl *http.Client, url string) (time.Duration, error) {
+	rc, err := FetchURL(ctx, cl, url)
 	if err != nil {
-		return fmt.Errorf("failed to open data file: %w", err)
+		return 0, err
 	}
-	defer file.Close()
-	indexFile, err := os.Create(dataFile + ".index")
-	if err != nil {
-		return fmt.Errorf("failed to create index file: %w", err)
-	}
-	defer indexFile.Close()
-	var off int64 = 0
-	if err := binary.Write(indexFile, binary.LittleEndian, off); err != nil {
-		return fmt.Errorf("write initial offset: %w", err)
-	}
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		off += int64(len(scanner.Bytes()) + 1)
-		if err := binary.Write(indexFile, binary.LittleEndian, off); err != nil {
-			return fmt.Errorf("write offset: %w", err)
+	defer rc.Close()
+	var slot struct {
+		WaitSeconds float64 `json:"wait_seconds"`
+	}
+	if err := json.NewDecoder(rc).Decode(&slot); err != nil {
+		return 0, fmt.Errorf("update slot: %w", err)
+	}
+	return time.Duration(slot.WaitSeconds * float64(time.Second)), nil
+}
+
+// fetch downloads url into memory so a failed registry aborts the update
+// before anything is written, and records its validators in cache. With
+// conditional set it sends the validators in cache and returns changed=false
+// and no data if the server answered 304 Not Modified. URLs other than http
+// and https go to their Fetcher.
+func fetch(ctx context.Context, cl *http.Client, url string, cache SourceCache, conditional bool) (b []byte, changed bool, err error) {
+	if !isHTTPScheme(urlScheme(url)) {
+		rc, err := FetchURL(ctx, cl, url)
+		if err != nil {
+			return nil, false, err
 		}
+		defer rc.Close()
+		b, err := io.ReadAll(rc)
+		return b, true, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if conditional {
+		cache.Condition(req)
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("status %d", resp.StatusCode)
 	}
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("scan vendors: %w", err)
+	cache.Record(url, resp)
+	b, err = io.ReadAll(resp.Body)
+	return b, true, err
+}
+
+// countEntries returns the number of lines in the entries file at path, or 0
+// if it does not exist.
+func countEntries(path string) int {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
 	}
-	return nil
+	return bytes.Count(b, []byte{'\n'})
 }
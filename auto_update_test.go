@@ -0,0 +1,205 @@
+//go:build oui_runtime_update
+
+package pg_oui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAutoUpdate_PreEntries(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(t.TempDir(), "oui.csv")
+	if err := os.WriteFile(src, []byte(testCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	open := func() {
+		t.Helper()
+		if _, err := Open(WithDir(dir), WithAutoUpdate(true), WithSourceURL(src)); err != nil {
+			t.Fatalf("open: %v", err)
+		}
+	}
+	open()
+
+	// Break the current dataset and change the source so that the next Open
+	// builds a new one.
+	if err := os.Remove(filepath.Join(dir, CurrentLink, defaultIndex)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte(testCSV+"MA-L,001126,Extra Inc,Addr 7\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	open()
+
+	recs, err := ReadUpdateHistory(dir)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("want 2 records, got %+v", recs)
+	}
+	if recs[1].PreEntries == 0 || recs[1].PreEntries != recs[0].PostEntries || recs[1].PostEntries <= recs[1].PreEntries {
+		t.Errorf("unexpected entry counts: %+v", recs)
+	}
+}
+
+func TestAutoUpdate_Conditional(t *testing.T) {
+	dir := t.TempDir()
+	var full, notModified atomic.Int32
+	var onNotModified func()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			if onNotModified != nil {
+				onNotModified()
+			}
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "oui.csv", time.Time{}, strings.NewReader(testCSV))
+	}))
+	defer srv.Close()
+	open := func() {
+		t.Helper()
+		if _, err := Open(WithDir(dir), WithAutoUpdate(true), WithSourceURL(srv.URL+"/oui.csv")); err != nil {
+			t.Fatalf("open: %v", err)
+		}
+	}
+	open()
+	if c, err := ReadSourceCache(dir); err != nil || c[srv.URL+"/oui.csv"].ETag != `"v1"` {
+		t.Fatalf("source cache = %v, %v", c, err)
+	}
+
+	// A dataset that another process completed while the request was under
+	// way is kept on 304.
+	index := filepath.Join(dir, CurrentLink, defaultIndex)
+	saved, err := os.ReadFile(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(index); err != nil {
+		t.Fatal(err)
+	}
+	onNotModified = func() { os.WriteFile(index, saved, 0o644) }
+	open()
+	if full.Load() != 1 || notModified.Load() != 1 {
+		t.Errorf("got %d full and %d conditional downloads, want 1 and 1", full.Load(), notModified.Load())
+	}
+	if recs, _ := ReadUpdateHistory(dir); len(recs) != 1 {
+		t.Errorf("rebuilt on 304: %+v", recs)
+	}
+
+	// Without a usable dataset a 304 is followed by a full download.
+	onNotModified = nil
+	if err := os.RemoveAll(filepath.Join(dir, DatasetsDir)); err != nil {
+		t.Fatal(err)
+	}
+	open()
+	if full.Load() != 2 || notModified.Load() != 2 {
+		t.Errorf("got %d full and %d conditional downloads, want 2 and 2", full.Load(), notModified.Load())
+	}
+}
+
+// registryServer serves testCSV and counts the downloads.
+func registryServer(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.Add(1)
+		fmt.Fprint(w, testCSV)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/oui.csv", &n
+}
+
+// setJitter makes the WithUpdateJitter delay fn(d) for the test.
+func setJitter(t *testing.T, fn func(d time.Duration) time.Duration) {
+	old := jitterN
+	jitterN = fn
+	t.Cleanup(func() { jitterN = old })
+}
+
+func TestAutoUpdate_MinInterval(t *testing.T) {
+	dir := t.TempDir()
+	url, downloads := registryServer(t)
+	if err := os.WriteFile(filepath.Join(dir, fetchMarker), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Open(WithDir(dir), WithAutoUpdate(true), WithSourceURL(url), WithMinUpdateInterval(time.Hour))
+	if !errors.Is(err, ErrDatasetNotFound) || downloads.Load() != 0 {
+		t.Errorf("recent marker: got %v after %d downloads, want ErrDatasetNotFound and none", err, downloads.Load())
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, fetchMarker), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(WithDir(dir), WithAutoUpdate(true), WithSourceURL(url), WithMinUpdateInterval(time.Hour)); err != nil || downloads.Load() != 1 {
+		t.Errorf("old marker: got %v after %d downloads, want one download", err, downloads.Load())
+	}
+}
+
+func TestAutoUpdate_JitterCanceled(t *testing.T) {
+	url, downloads := registryServer(t)
+	setJitter(t, func(d time.Duration) time.Duration { return d })
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := OpenContext(ctx, WithDir(t.TempDir()), WithAutoUpdate(true), WithSourceURL(url), WithUpdateJitter(time.Hour))
+	if !errors.Is(err, context.DeadlineExceeded) || downloads.Load() != 0 {
+		t.Errorf("got %v after %d downloads, want the context's error and none", err, downloads.Load())
+	}
+}
+
+func TestAutoUpdate_JitterReusesDataset(t *testing.T) {
+	dir := t.TempDir()
+	url, downloads := registryServer(t)
+	// Another process builds the dataset while this one waits.
+	setJitter(t, func(time.Duration) time.Duration {
+		src := t.TempDir()
+		if _, err := Build(strings.NewReader(testCSV), src, nil); err != nil {
+			t.Errorf("build: %v", err)
+		}
+		if _, err := StoreDataset(dir, src); err != nil {
+			t.Errorf("store: %v", err)
+		}
+		return 0
+	})
+	db, err := Open(WithDir(dir), WithAutoUpdate(true), WithSourceURL(url), WithUpdateJitter(time.Hour))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if v, _ := db.Lookup("00:11:22"); v != "Sony" || downloads.Load() != 0 {
+		t.Errorf("got %q after %d downloads, want Sony and none", v, downloads.Load())
+	}
+}
+
+func TestAutoUpdate_Scheduler(t *testing.T) {
+	url, downloads := registryServer(t)
+	wait := "3600"
+	sched := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"wait_seconds":%s}`, wait)
+	}))
+	defer sched.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := OpenContext(ctx, WithDir(t.TempDir()), WithAutoUpdate(true), WithSourceURL(url), WithUpdateScheduler(sched.URL))
+	if !errors.Is(err, context.DeadlineExceeded) || downloads.Load() != 0 {
+		t.Errorf("got %v after %d downloads, want to wait for the slot", err, downloads.Load())
+	}
+
+	wait = "0"
+	if _, err := Open(WithDir(t.TempDir()), WithAutoUpdate(true), WithSourceURL(url), WithUpdateScheduler(sched.URL)); err != nil || downloads.Load() != 1 {
+		t.Errorf("got %v after %d downloads, want one download", err, downloads.Load())
+	}
+}
@@ -0,0 +1,137 @@
+package pg_oui
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// benchDataset builds a registry-sized dataset: 35000 prefixes (28000 MA-L,
+// 3500 MA-M and 3500 MA-S) spread over 20000 vendors.
+func benchDataset(b *testing.B) (string, []string) {
+	b.Helper()
+	rng := rand.New(rand.NewPCG(1, 2))
+	var csv strings.Builder
+	csv.WriteString("Registry,Assignment,Organization Name,Organization Address\n")
+	var macs []string
+	for i, reg := range []struct {
+		name   string
+		n, len int
+	}{{"MA-L", 28000, 6}, {"MA-M", 3500, 7}, {"MA-S", 3500, 9}} {
+		for j := 0; j < reg.n; j++ {
+			p := fmt.Sprintf("%09x", rng.Uint64N(1<<36))[:reg.len]
+			fmt.Fprintf(&csv, "%s,%s,Vendor %d,Addr\n", reg.name, p, rng.IntN(20000))
+			if j%10 == i {
+				macs = append(macs, p+"000000"[:12-reg.len])
+			}
+		}
+	}
+	dir := b.TempDir()
+	if _, err := Build(strings.NewReader(csv.String()), dir, nil); err != nil {
+		b.Fatalf("build: %v", err)
+	}
+	return dir, macs
+}
+
+// BenchmarkOpen reports the heap retained by the prefix index of an opened
+// registry-sized DB, i.e. excluding the vendor names and offsets. On a
+// 2.4 GHz amd64 core:
+//
+//	                     index-B   Open      Lookup
+//	map[string]int       2352675   16.2ms    215ns
+//	sorted prefixTable    363419   25.5ms    240ns
+func BenchmarkOpen(b *testing.B) {
+	dir, _ := benchDataset(b)
+	var before, after runtime.MemStats
+	var db *DB
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		var err error
+		if db, err = Open(WithDir(dir)); err != nil {
+			b.Fatalf("open: %v", err)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+	}
+	d := db.cur.Load()
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc-uint64(len(d.vendors)+8*len(d.offsets))), "index-B")
+	runtime.KeepAlive(db)
+}
+
+func BenchmarkLookup(b *testing.B) {
+	dir, macs := benchDataset(b)
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		b.Fatalf("open: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.Lookup(macs[i%len(macs)])
+	}
+}
+
+// BenchmarkLookup_Colons looks up "aa:bb:cc:dd:ee:ff" style input, which
+// like bare hex digits takes the allocation-free path.
+func BenchmarkLookup_Colons(b *testing.B) {
+	dir, macs := benchDataset(b)
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		b.Fatalf("open: %v", err)
+	}
+	for i, m := range macs {
+		macs[i] = strings.ToUpper(m[:2] + ":" + m[2:4] + ":" + m[4:6] + ":" + m[6:8] + ":" + m[8:10] + ":" + m[10:])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.Lookup(macs[i%len(macs)])
+	}
+}
+
+func TestLookup_NoAllocs(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, in := range []string{"001122334455", "00:11:22:33:44:55", "00-11-22-33-44-55\n", "0011.2233.4455", "ffffff"} {
+		if n := testing.AllocsPerRun(100, func() { db.Lookup(in) }); n != 0 {
+			t.Errorf("Lookup(%q) allocates %v times, want 0", in, n)
+		}
+	}
+}
+
+func TestPrefixTable(t *testing.T) {
+	tab := newPrefixTable(map[string]int{
+		"000000": 0, "fffffe": 1, "0050c2": 2, "0050c21": 3, "0050c2123": 4,
+		"ABCDEF": 5, "00a": 6,
+	})
+	for key, want := range map[string]string{
+		"0050c2123456": "0050c2123",
+		"0050c2129999": "0050c21",
+		"0050c2999999": "0050c2",
+		"fffffe":       "fffffe",
+		"000000000":    "000000",
+		"ffffff":       "",
+		"abcdef":       "",
+	} {
+		if p, _, _ := tab.match(key); p != want {
+			t.Errorf("match(%q) = %q, want %q", key, p, want)
+		}
+	}
+	if id, ok := tab.get("ABCDEF"); !ok || id != 5 {
+		t.Errorf("get(ABCDEF) = %d, %v; want 5, true", id, ok)
+	}
+	want := []string{"000000", "0050c2", "0050c21", "0050c2123", "00a", "ABCDEF", "fffffe"}
+	if got := tab.sorted(); !slices.Equal(got, want) || tab.len() != len(want) {
+		t.Errorf("sorted() = %q (len %d), want %q", got, tab.len(), want)
+	}
+}
@@ -0,0 +1,114 @@
+package pg_oui
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// BinaryName is the single-file dataset written with BuildOptions.Binary.
+// Open prefers it over the three-file layout when both are present.
+const BinaryName = "oui.bin"
+
+// Binary dataset layout, all integers little-endian:
+//
+//	"PGOUIBIN" | uint32 version | uint32 CRC-32 (IEEE) of the rest of the file
+//	uint32 vendor count V | uint32 entry count E | uint32 string table size S
+//	(V+1) x uint32 vendor offsets into the string table
+//	S bytes of vendor names, each ending in '\n'
+//	E x 12-byte entries sorted by prefix: 5 bytes of prefix nibbles
+//	(left-aligned), uint8 nibble count, 2 reserved bytes, uint32 vendor ID
+const (
+	binaryMagic      = "PGOUIBIN"
+	binaryVersion    = 1
+	binaryHeaderSize = 28
+	binaryEntrySize  = 12
+)
+
+// writeBinary writes a binary dataset of the given vendors file contents and
+// n entries, which must be sorted by prefix.
+func writeBinary(w io.Writer, vendors []byte, n int, entry func(i int) (oui string, id int)) error {
+	var offsets []uint32
+	offsets = append(offsets, 0)
+	for i, b := range vendors {
+		if b == '\n' {
+			offsets = append(offsets, uint32(i+1))
+		}
+	}
+	if len(vendors) > 0 && vendors[len(vendors)-1] != '\n' {
+		offsets = append(offsets, uint32(len(vendors)))
+	}
+	var body []byte
+	for _, off := range offsets {
+		body = binary.LittleEndian.AppendUint32(body, off)
+	}
+	body = append(body, vendors...)
+	for i := 0; i < n; i++ {
+		oui, id := entry(i)
+		if len(oui) < 6 || len(oui) > 9 {
+			return fmt.Errorf("prefix %q: want 6 to 9 hex digits", oui)
+		}
+		var rec [binaryEntrySize]byte
+		p, err := hex.DecodeString(oui + strings.Repeat("0", 10-len(oui)))
+		if err != nil {
+			return fmt.Errorf("prefix %q: %w", oui, err)
+		}
+		copy(rec[:5], p)
+		rec[5] = byte(len(oui))
+		binary.LittleEndian.PutUint32(rec[8:], uint32(id))
+		body = append(body, rec[:]...)
+	}
+
+	hdr := make([]byte, 0, binaryHeaderSize)
+	hdr = append(hdr, binaryMagic...)
+	hdr = binary.LittleEndian.AppendUint32(hdr, binaryVersion)
+	hdr = binary.LittleEndian.AppendUint32(hdr, crc32.ChecksumIEEE(body))
+	hdr = binary.LittleEndian.AppendUint32(hdr, uint32(len(offsets)-1))
+	hdr = binary.LittleEndian.AppendUint32(hdr, uint32(n))
+	hdr = binary.LittleEndian.AppendUint32(hdr, uint32(len(vendors)))
+	bw := bufio.NewWriter(w)
+	bw.Write(hdr)
+	bw.Write(body)
+	return bw.Flush()
+}
+
+// readBinary decodes a binary dataset into the entries map, vendors contents
+// and offsets used by DB.
+func readBinary(b []byte) (map[string]int, []byte, []int64, error) {
+	if len(b) < binaryHeaderSize || string(b[:8]) != binaryMagic {
+		return nil, nil, nil, fmt.Errorf("not a binary dataset")
+	}
+	le := binary.LittleEndian
+	if v := le.Uint32(b[8:]); v != binaryVersion {
+		return nil, nil, nil, fmt.Errorf("unsupported binary dataset version %d", v)
+	}
+	body := b[binaryHeaderSize:]
+	if want, got := le.Uint32(b[12:]), crc32.ChecksumIEEE(body); want != got {
+		return nil, nil, nil, fmt.Errorf("binary dataset checksum mismatch (crc32 %08x, want %08x)", got, want)
+	}
+	nv, ne, ns := int(le.Uint32(b[16:])), int(le.Uint32(b[20:])), int(le.Uint32(b[24:]))
+	if want := (nv+1)*4 + ns + ne*binaryEntrySize; len(body) != want {
+		return nil, nil, nil, fmt.Errorf("binary dataset is %d bytes, header says %d", len(body), want)
+	}
+	offsets := make([]int64, nv+1)
+	for i := range offsets {
+		offsets[i] = int64(le.Uint32(body[i*4:]))
+	}
+	body = body[(nv+1)*4:]
+	vendors := body[:ns:ns]
+	body = body[ns:]
+	entries := make(map[string]int, ne)
+	for i := 0; i < ne; i++ {
+		rec := body[i*binaryEntrySize:]
+		n := int(rec[5])
+		if n < 6 || n > 9 {
+			return nil, nil, nil, fmt.Errorf("entry %d: bad prefix length %d", i, n)
+		}
+		entries[hex.EncodeToString(rec[:5])[:n]] = int(le.Uint32(rec[8:]))
+	}
+	return entries, vendors, offsets, nil
+}
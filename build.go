@@ -0,0 +1,477 @@
+package pg_oui
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// registryURLs maps IEEE registry names to their CSV downloads.
+var registryURLs = map[string]string{
+	"MA-L": "https://standards-oui.ieee.org/oui/oui.csv",
+	"MA-M": "https://standards-oui.ieee.org/oui28/mam.csv",
+	"MA-S": "https://standards-oui.ieee.org/oui36/oui36.csv",
+	"CID":  "https://standards-oui.ieee.org/cid/cid.csv",
+}
+
+// DefaultRegistries are the registries downloaded when none are selected:
+// MA-L (24-bit), MA-M (28-bit) and MA-S (36-bit) assignments.
+var DefaultRegistries = []string{"MA-L", "MA-M", "MA-S"}
+
+// RegistryURLs returns the CSV download URLs for the named registries
+// (MA-L, MA-M, MA-S, CID; case-insensitive), or for DefaultRegistries if
+// names is empty.
+func RegistryURLs(names []string) ([]string, error) {
+	if len(names) == 0 {
+		names = DefaultRegistries
+	}
+	var urls []string
+	for _, n := range names {
+		u, ok := registryURLs[strings.ToUpper(strings.TrimSpace(n))]
+		if !ok {
+			return nil, fmt.Errorf("unknown registry %q (want MA-L, MA-M, MA-S or CID)", n)
+		}
+		if !slices.Contains(urls, u) {
+			urls = append(urls, u)
+		}
+	}
+	return urls, nil
+}
+
+// BuildOptions controls how a dataset is generated from an IEEE registry CSV.
+// The zero value builds the full dataset with simplified vendor names. It is
+// shared by cmd/update_data, the runtime auto-update builder, and Build.
+type BuildOptions struct {
+	// Includes: when non-empty, only matching rows are kept. Vendor names
+	// are matched after simplification (LLC/Ltd/Inc removal).
+	IncludeVendors []string
+	VendorRegex    *regexp.Regexp
+	IncludeOUIs    []string // strings like 0CB4A4 or 00:11:22
+
+	// Excludes: matching rows are dropped, even if included above.
+	ExcludeVendors []string
+	ExcludeRegex   *regexp.Regexp
+	ExcludeOUIs    []string
+
+	// Registries keeps only rows from these registries (e.g. "MA-L"),
+	// compared case-insensitively. Empty keeps all.
+	Registries []string
+
+	// KeepRawNames disables LLC/Ltd/Inc/Co/GmbH suffix simplification.
+	KeepRawNames bool
+	// SanitizeVendors strips control/format characters and trademark symbols.
+	SanitizeVendors bool
+	// MaxVendorLen truncates vendor names to this many characters; 0 means
+	// unlimited.
+	MaxVendorLen int
+	// Aliases maps vendor names to a canonical name, see ReadAliases.
+	// Names match ignoring case, punctuation and the suffixes
+	// simplification removes, so "INTEL CORP" matches an alias for "Intel".
+	Aliases map[string]string
+
+	// Source is the input format: "" or SourceIEEE for IEEE registry CSVs,
+	// SourceWireshark for Wireshark's manuf file, SourceNmap for
+	// nmap-mac-prefixes.
+	Source string
+
+	// Format selects the entries file format: "" or EntriesV1 for
+	// OUI,VendorID CSV, EntriesV2 for the tab-separated v2 format, which
+	// also keeps each row's registry, raw name, address and short name for
+	// LookupRecord.
+	Format string
+	// Binary writes the single-file BinaryName dataset instead of entries,
+	// vendors and vendors.index; Format is ignored.
+	Binary bool
+	// Origin is recorded as the source in DatasetInfoName, e.g. the
+	// registry URLs the input was downloaded from.
+	Origin string
+	// Attribution is recorded in DatasetInfoName and reported by
+	// Metadata, see SourceAttribution. A zero RetrievedAt is taken to be the
+	// build time.
+	Attribution Attribution
+	// BuiltAt is recorded as the build time in DatasetInfoName. Zero uses
+	// SOURCE_DATE_EPOCH if set, for reproducible builds, else the current
+	// time.
+	BuiltAt time.Time
+	// Duplicates decides which row wins when an OUI appears more than once.
+	// DuplicateDefault keeps the first.
+	Duplicates DuplicatePolicy
+
+	// Progress, if set, is called with the number of CSV rows parsed so far
+	// every 1000 rows and once when parsing is done.
+	Progress func(rows int)
+}
+
+// BuildResult summarizes a Build.
+type BuildResult struct {
+	Entries int
+	Vendors int
+	// Issues lists vendor names changed by the name policy and duplicate
+	// OUIs, for the quality report.
+	Issues []string
+}
+
+var (
+	llcRegex  = regexp.MustCompile(`(?i),?\s*(llc|ltd|limited|inc|incorporated)\.?$`)
+	coRegex   = regexp.MustCompile(`(?i),?\s*(co|company|corp|corporation)\.?$`)
+	gmbhRegex = regexp.MustCompile(`(?i),?\s*gmbh\.?$`)
+)
+
+func simplifyName(name string) string {
+	b := []byte(name)
+	b = llcRegex.ReplaceAll(b, []byte{})
+	b = coRegex.ReplaceAll(b, []byte{})
+	b = gmbhRegex.ReplaceAll(b, []byte{})
+	return strings.TrimSpace(string(b))
+}
+
+// cleanOUI strips separators and returns the lower-case first 6 hex chars,
+// or "" if s is too short.
+func cleanOUI(s string) string {
+	s = macCleaner.Replace(strings.ToLower(strings.TrimSpace(s)))
+	if len(s) < 6 {
+		return ""
+	}
+	return s[:6]
+}
+
+// buildPlan is BuildOptions compiled into lookup sets.
+type buildPlan struct {
+	opts                  *BuildOptions
+	incVendors, excVendor map[string]bool
+	incOUIs, excOUIs      map[string]bool
+	registries            map[string]bool
+	aliases               map[string]string // by aliasKey
+}
+
+func newBuildPlan(o *BuildOptions) *buildPlan {
+	p := &buildPlan{opts: o, aliases: newAliasTable(o.Aliases)}
+	vendorSet := func(names []string) map[string]bool {
+		m := map[string]bool{}
+		for _, v := range names {
+			if v = p.name(strings.TrimSpace(v)); v != "" {
+				m[v] = true
+			}
+		}
+		return m
+	}
+	ouiSet := func(ouis []string) map[string]bool {
+		m := map[string]bool{}
+		for _, o := range ouis {
+			if o = cleanOUI(o); o != "" {
+				m[o] = true
+			}
+		}
+		return m
+	}
+	p.incVendors, p.excVendor = vendorSet(o.IncludeVendors), vendorSet(o.ExcludeVendors)
+	p.incOUIs, p.excOUIs = ouiSet(o.IncludeOUIs), ouiSet(o.ExcludeOUIs)
+	p.registries = map[string]bool{}
+	for _, r := range o.Registries {
+		p.registries[strings.ToUpper(strings.TrimSpace(r))] = true
+	}
+	return p
+}
+
+// name returns the vendor name as matched by filters.
+func (p *buildPlan) name(v string) string {
+	if p.opts.KeepRawNames {
+		return v
+	}
+	return simplifyName(v)
+}
+
+func (p *buildPlan) allowRegistry(r string) bool {
+	return len(p.registries) == 0 || p.registries[strings.ToUpper(strings.TrimSpace(r))]
+}
+
+// allowOUI matches OUI filters against the MA-L part of the prefix o, so
+// MA-M and MA-S blocks follow the OUI they are carved from.
+func (p *buildPlan) allowOUI(o string) bool {
+	if len(o) < 6 {
+		return false
+	}
+	if len(p.incOUIs) > 0 && !p.incOUIs[o[:6]] {
+		return false
+	}
+	return !p.excOUIs[o[:6]]
+}
+
+func (p *buildPlan) allowVendor(v string) bool {
+	if len(p.incVendors) > 0 && !p.incVendors[v] {
+		return false
+	}
+	if p.opts.VendorRegex != nil && !p.opts.VendorRegex.MatchString(v) {
+		return false
+	}
+	if p.excVendor[v] {
+		return false
+	}
+	return p.opts.ExcludeRegex == nil || !p.opts.ExcludeRegex.MatchString(v)
+}
+
+// policy applies aliases and the name policy, returning the final name and a
+// description of each change made by the policy.
+func (p *buildPlan) policy(name string) (string, []string) {
+	if p.aliases != nil {
+		if c, ok := p.aliases[aliasKey(name)]; ok {
+			name = c
+		}
+	}
+	var issues []string
+	if p.opts.SanitizeVendors {
+		clean := strings.Join(strings.Fields(strings.Map(func(r rune) rune {
+			switch {
+			case r == '™' || r == '®' || r == '©' || r == '℠':
+				return -1
+			case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+				return -1
+			}
+			return r
+		}, name)), " ")
+		if clean != name {
+			issues = append(issues, fmt.Sprintf("sanitized %q -> %q", name, clean))
+			name = clean
+		}
+	}
+	if p.opts.MaxVendorLen > 0 {
+		if r := []rune(name); len(r) > p.opts.MaxVendorLen {
+			short := strings.TrimSpace(string(r[:p.opts.MaxVendorLen]))
+			issues = append(issues, fmt.Sprintf("truncated %q (%d chars) -> %q", name, len(r), short))
+			name = short
+		}
+	}
+	return name, issues
+}
+
+// Build reads IEEE registry CSVs (Registry,Assignment,Organization
+// Name,...), or the manuf or nmap file named by opts.Source, from r and
+// writes entries, vendors and vendors.index to outdir, or stores them with
+// StoreDataset if outdir has a CurrentLink. r may hold several registries
+// (MA-L, MA-M, MA-S) concatenated; their header rows are skipped. A nil
+// opts builds the full dataset.
+func Build(r io.Reader, outdir string, opts *BuildOptions) (*BuildResult, error) {
+	if opts == nil {
+		opts = &BuildOptions{}
+	}
+	if opts.Format != "" && opts.Format != EntriesV1 && opts.Format != EntriesV2 {
+		return nil, fmt.Errorf("unsupported output format %q", opts.Format)
+	}
+	p := newBuildPlan(opts)
+	res := &BuildResult{}
+	h := sha256.New()
+	r = io.TeeReader(r, h)
+
+	var next func() ([]string, error)
+	switch opts.Source {
+	case "", SourceIEEE:
+		c := csv.NewReader(r)
+		c.FieldsPerRecord = -1
+		if _, err := c.Read(); err != nil { // header
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("empty CSV")
+			}
+			return nil, fmt.Errorf("read header: %w", err)
+		}
+		next = c.Read
+	case SourceWireshark:
+		next = manufRows(r)
+	case SourceNmap:
+		next = nmapRows(r)
+	default:
+		return nil, fmt.Errorf("unsupported source format %q", opts.Source)
+	}
+
+	type row struct{ oui, vendor, registry, raw, address, short string }
+	var rows []row
+	seen := make(map[string]int) // OUI -> index in rows
+	reported := make(map[string]bool)
+	for n := 1; ; n++ {
+		rec, err := next()
+		if errors.Is(err, io.EOF) {
+			if opts.Progress != nil {
+				opts.Progress(n - 1)
+			}
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read row: %w", err)
+		}
+		if opts.Progress != nil && n%1000 == 0 {
+			opts.Progress(n)
+		}
+		if len(rec) < 3 || rec[0] == "Registry" || !p.allowRegistry(rec[0]) {
+			continue
+		}
+		o := strings.ToLower(strings.TrimSpace(rec[1]))
+		if !p.allowOUI(o) {
+			continue
+		}
+		v := p.name(strings.ReplaceAll(strings.TrimSpace(rec[2]), `"`, ""))
+		if !p.allowVendor(v) {
+			continue
+		}
+		nv, issues := p.policy(v)
+		if len(issues) > 0 && !reported[v] {
+			reported[v] = true
+			res.Issues = append(res.Issues, issues...)
+		}
+		v = nv
+		rw := row{oui: o, vendor: v, registry: strings.TrimSpace(rec[0]), raw: strings.TrimSpace(rec[2])}
+		if len(rec) > 3 {
+			rw.address = strings.TrimSpace(rec[3])
+		}
+		if len(rec) > 4 && strings.TrimSpace(rec[4]) != "" && opts.Source == SourceWireshark {
+			rw.short = strings.TrimSpace(rec[4])
+		} else {
+			rw.short = ShortName(rw.raw)
+		}
+		if i, ok := seen[o]; ok { // 080030 is a known duplicate
+			res.Issues = append(res.Issues, fmt.Sprintf("duplicate OUI %s: %q already registered to %q", o, v, rows[i].vendor))
+			switch opts.Duplicates {
+			case DuplicateError:
+				return nil, fmt.Errorf("duplicate OUI %s", o)
+			case DuplicateLast:
+				rows[i] = rw
+			}
+			continue
+		}
+		seen[o] = len(rows)
+		rows = append(rows, rw)
+	}
+
+	// Vendor IDs follow first appearance in the input.
+	vendorIDs := make(map[string]int)
+	var vendors []string
+	type entry struct {
+		oui   string
+		id    int
+		extra []string
+	}
+	entries := make([]entry, 0, len(rows))
+	for _, r := range rows {
+		id, ok := vendorIDs[r.vendor]
+		if !ok {
+			id = len(vendors)
+			vendorIDs[r.vendor] = id
+			vendors = append(vendors, r.vendor)
+		}
+		entries = append(entries, entry{oui: r.oui, id: id, extra: []string{r.registry, r.raw, r.address, r.short}})
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.oui, b.oui) })
+
+	if err := os.MkdirAll(outdir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir outdir: %w", err)
+	}
+	// Write next to outdir and rename into place, so Open never reads a
+	// half-written file.
+	tmp, err := os.MkdirTemp(outdir, ".build-")
+	if err != nil {
+		return nil, fmt.Errorf("build dataset: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	var vendorsData []byte
+	for _, v := range vendors {
+		vendorsData = append(append(vendorsData, v...), '\n')
+	}
+	res.Entries, res.Vendors = len(entries), len(vendors)
+	if opts.Binary {
+		err := writeFile(filepath.Join(tmp, BinaryName), func(w *bufio.Writer) error {
+			return writeBinary(w, vendorsData, len(entries), func(i int) (string, int) { return entries[i].oui, entries[i].id })
+		})
+		if err != nil {
+			return nil, fmt.Errorf("write binary dataset: %w", err)
+		}
+	} else {
+		err := writeFile(filepath.Join(tmp, defaultEntries), func(w *bufio.Writer) error {
+			return encodeEntries(w, opts.Format, len(entries), func(i int) (string, int) { return entries[i].oui, entries[i].id }, func(i int) []string { return entries[i].extra })
+		})
+		if err != nil {
+			return nil, fmt.Errorf("write entries: %w", err)
+		}
+		err = writeFile(filepath.Join(tmp, defaultVendors), func(w *bufio.Writer) error {
+			_, err := w.Write(vendorsData)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("write vendors: %w", err)
+		}
+		err = writeFile(filepath.Join(tmp, defaultIndex), func(w *bufio.Writer) error {
+			return writeIndex(w, vendorsData)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("write index: %w", err)
+		}
+	}
+	if err := writeInfo(tmp, opts, h.Sum(nil), res); err != nil {
+		return nil, err
+	}
+	// A data dir in the content-addressed layout switches datasets with one
+	// rename of its current link; the flat files are renamed one by one.
+	if _, err := os.Lstat(filepath.Join(outdir, CurrentLink)); err == nil {
+		if _, err := StoreDataset(outdir, tmp); err != nil {
+			return nil, fmt.Errorf("build dataset: %w", err)
+		}
+		return res, nil
+	}
+	if err := replaceDataset(outdir, tmp); err != nil {
+		return nil, fmt.Errorf("build dataset: %w", err)
+	}
+	return res, nil
+}
+
+// writeInfo writes DatasetInfoName for a dataset built from input with the
+// given SHA-256.
+func writeInfo(outdir string, opts *BuildOptions, sum []byte, res *BuildResult) error {
+	info := DatasetInfo{Version: hex.EncodeToString(sum)[:12], BuiltAt: opts.BuiltAt, Source: opts.Origin, Entries: res.Entries, Vendors: res.Vendors}
+	if info.BuiltAt.IsZero() {
+		info.BuiltAt = time.Now()
+		if sec, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+			info.BuiltAt = time.Unix(sec, 0)
+		}
+	}
+	info.BuiltAt = info.BuiltAt.UTC().Truncate(time.Second)
+	info.Attribution = opts.Attribution
+	if info.Attribution.Name != "" && info.Attribution.RetrievedAt.IsZero() {
+		info.Attribution.RetrievedAt = info.BuiltAt
+	}
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode dataset info: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outdir, DatasetInfoName), append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write dataset info: %w", err)
+	}
+	return nil
+}
+
+// writeFile creates path and writes it through a buffered writer.
+func writeFile(path string, fill func(w *bufio.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := fill(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,465 @@
+package pg_oui
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCSV = `Registry,Assignment,Organization Name,Organization Address
+MA-L,0CB4A4,"Nokia Solutions and Networks, Inc.",Addr 1
+MA-L,001122,Sony Corporation,Addr 2
+MA-L,001123,Acme GmbH,Addr 3
+MA-L,001124,Acme Labs LLC,Addr 4
+MA-M,001125,Sony Corporation,Addr 5
+MA-L,001122,Sony Duplicate Ltd,Addr 6
+`
+
+func TestBuild_Options(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts *BuildOptions
+		want map[string]string // OUI -> vendor; "" means absent
+	}{
+		{"all", nil, map[string]string{
+			"0cb4a4": "Nokia Solutions and Networks",
+			"001122": "Sony",
+			"001123": "Acme",
+			"001125": "Sony",
+		}},
+		{"include and exclude", &BuildOptions{
+			VendorRegex:    regexp.MustCompile(`^(Sony|Acme)`),
+			ExcludeVendors: []string{"Acme Labs LLC"},
+			ExcludeOUIs:    []string{"00:11:25"},
+		}, map[string]string{
+			"0cb4a4": "",
+			"001122": "Sony",
+			"001123": "Acme",
+			"001124": "",
+			"001125": "",
+		}},
+		{"registries and last duplicate", &BuildOptions{
+			Registries: []string{"ma-l"},
+			Duplicates: DuplicateLast,
+		}, map[string]string{
+			"001122": "Sony Duplicate",
+			"001125": "",
+		}},
+		{"v2 entries", &BuildOptions{Format: EntriesV2}, map[string]string{
+			"0cb4a4": "Nokia Solutions and Networks",
+			"001125": "Sony",
+		}},
+		{"raw names and aliases", &BuildOptions{
+			KeepRawNames: true,
+			Aliases:      map[string]string{"Acme GmbH": "ACME", "Acme Labs LLC": "ACME"},
+		}, map[string]string{
+			"001122": "Sony Corporation",
+			"001123": "ACME",
+			"001124": "ACME",
+		}},
+		{"aliases ignore case and suffixes", &BuildOptions{
+			Aliases: map[string]string{"SONY CORP": "Sony Group", "acme-labs": "Acme"},
+		}, map[string]string{
+			"001122": "Sony Group",
+			"001123": "Acme",
+			"001124": "Acme",
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if _, err := Build(strings.NewReader(testCSV), dir, tc.opts); err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			db, err := Open(WithDir(dir), WithValidation(ValidateThorough))
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			for oui, want := range tc.want {
+				if got, _ := db.Lookup(oui); got != want {
+					t.Errorf("%s: got %q, want %q", oui, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuild_DuplicateError(t *testing.T) {
+	_, err := Build(strings.NewReader(testCSV), t.TempDir(), &BuildOptions{Duplicates: DuplicateError})
+	if err == nil || !strings.Contains(err.Error(), "001122") {
+		t.Fatalf("want duplicate error for 001122, got %v", err)
+	}
+}
+
+func TestBuild_LongestPrefix(t *testing.T) {
+	csv := "Registry,Assignment,Organization Name,Organization Address\n" +
+		"MA-L,70B3D5,IEEE Registration Authority,Addr\n\n" +
+		"Registry,Assignment,Organization Name,Organization Address\n" +
+		"MA-M,70B3D51,Block Vendor,Addr\n\n" +
+		"Registry,Assignment,Organization Name,Organization Address\n" +
+		"MA-S,70B3D5123,Small Vendor,Addr\n"
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(csv), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := Open(WithDir(dir), WithValidation(ValidateThorough))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for mac, want := range map[string]string{
+		"70:b3:d5:12:34:56": "Small Vendor",
+		"70:b3:d5:1f:00:00": "Block Vendor",
+		"70:b3:d5:20:00:00": "IEEE Registration Authority",
+		"70b3d5":            "IEEE Registration Authority",
+	} {
+		if got, _ := db.Lookup(mac); got != want {
+			t.Errorf("%s: got %q, want %q", mac, got, want)
+		}
+	}
+	if got, _ := db.LookupFromHardwareAddr([]byte{0x70, 0xb3, 0xd5, 0x12, 0x34, 0x56}); got != "Small Vendor" {
+		t.Errorf("hardware addr: got %q, want Small Vendor", got)
+	}
+	for mac, want := range map[string]struct {
+		bits int
+		conf Confidence
+	}{
+		"70:b3:d5:12:34:56": {36, ConfidenceHigh},
+		"70:b3:d5:1f:00:00": {28, ConfidenceLow},
+		"70:b3:d5:20:00:00": {24, ConfidenceLow},
+		"02:42:ac:11:00:02": {16, ConfidenceHigh},
+	} {
+		rec, err := db.LookupRecord(mac)
+		if err != nil || rec.Bits != want.bits || rec.Confidence != want.conf {
+			t.Errorf("%s: got %+v, %v, want %d bits, %s confidence", mac, rec, err, want.bits, want.conf)
+		}
+	}
+}
+
+func TestBuild_ReplacesDataset(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, &BuildOptions{Binary: true}); err != nil {
+		t.Fatalf("build binary: %v", err)
+	}
+	if _, err := Build(strings.NewReader(testCSV), dir, &BuildOptions{IncludeVendors: []string{"Sony"}}); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range ents {
+		names = append(names, e.Name())
+	}
+	if want := []string{DatasetInfoName, defaultEntries, defaultVendors, defaultIndex}; !slices.Equal(slices.Sorted(slices.Values(names)), slices.Sorted(slices.Values(want))) {
+		t.Errorf("files after rebuild: %v, want %v", names, want)
+	}
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if n := db.Len(); n != 2 {
+		t.Errorf("got %d entries, want the 2 of the second build", n)
+	}
+}
+
+func TestLockDir(t *testing.T) {
+	dir := t.TempDir()
+	unlock, err := LockDir(dir)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if _, err := LockDir(dir); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second lock: got err %v, want ErrLocked", err)
+	}
+	unlock()
+	unlock, err = LockDir(dir)
+	if err != nil {
+		t.Fatalf("lock after unlock: %v", err)
+	}
+	unlock()
+}
+
+func TestReadEntries_V2ExtraFields(t *testing.T) {
+	in := entriesV2Header + "\n" +
+		"0cb4a4\t0\tNokia, Inc.\tEspoo, FI\n" +
+		"# comment\n" +
+		"001122\t1\n" +
+		"bad line\n"
+	got := map[string][]string{}
+	err := readEntries(strings.NewReader(in), func(oui string, id int, extra []string) {
+		got[oui] = extra
+	})
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(got) != 2 || strings.Join(got["0cb4a4"], "|") != "Nokia, Inc.|Espoo, FI" {
+		t.Fatalf("unexpected entries: %q", got)
+	}
+}
+
+func TestRegistryURLs(t *testing.T) {
+	def, err := RegistryURLs(nil)
+	if err != nil || len(def) != 3 {
+		t.Fatalf("default registries: %v, %v", def, err)
+	}
+	got, err := RegistryURLs([]string{"ma-l", "cid", "MA-L"})
+	if err != nil || len(got) != 2 || !strings.HasSuffix(got[1], "/cid.csv") {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if _, err := RegistryURLs([]string{"ma-x"}); err == nil {
+		t.Fatal("want error for unknown registry")
+	}
+}
+
+func TestOUIsForVendor(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got, err := db.OUIsForVendor("sony"); err != nil || strings.Join(got, ",") != "001122,001125" {
+		t.Errorf("exact: got %v, %v", got, err)
+	}
+	if got, err := db.OUIsForVendorFuzzy("ac me"); err != nil || strings.Join(got, ",") != "001123,001124" {
+		t.Errorf("fuzzy: got %v, %v", got, err)
+	}
+	if got, err := db.OUIsForVendorRegexp(regexp.MustCompile(`^(Sony|Acme L)`)); err != nil || strings.Join(got, ",") != "001122,001124,001125" {
+		t.Errorf("regexp: got %v, %v", got, err)
+	}
+	if _, err := db.OUIsForVendor("Acm"); err != ErrNotFound {
+		t.Errorf("want ErrNotFound, got %v", err)
+	}
+}
+
+func TestLookupRecord(t *testing.T) {
+	for _, format := range []string{EntriesV1, EntriesV2} {
+		dir := t.TempDir()
+		if _, err := Build(strings.NewReader(testCSV), dir, &BuildOptions{Format: format}); err != nil {
+			t.Fatalf("%s: build: %v", format, err)
+		}
+		db, err := Open(WithDir(dir))
+		if err != nil {
+			t.Fatalf("%s: open: %v", format, err)
+		}
+		got, err := db.LookupRecord("0c:b4:a4:01:02:03")
+		if err != nil {
+			t.Fatalf("%s: lookup: %v", format, err)
+		}
+		want := Record{Prefix: "0cb4a4", Vendor: "Nokia Solutions and Networks", ShortName: "NokiaSol", Source: LayerRegistry, Bits: 24, Confidence: ConfidenceHigh}
+		if format == EntriesV2 {
+			want.Registry, want.RawName, want.Address = "MA-L", "Nokia Solutions and Networks, Inc.", "Addr 1"
+		}
+		if got != want {
+			t.Errorf("%s: got %+v, want %+v", format, got, want)
+		}
+	}
+}
+
+func TestCanonicalVendor(t *testing.T) {
+	aliases, err := ReadAliases(strings.NewReader("# Intel\nIntel Corporate, Intel\n\"Intel, Inc.\",Intel\n"))
+	if err != nil {
+		t.Fatalf("read aliases: %v", err)
+	}
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := Open(WithDir(dir), WithAliases(aliases))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for in, want := range map[string]string{
+		"Intel Corporate":  "Intel",
+		"INTEL CORP":       "Intel",
+		"intel":            "Intel",
+		"SONY CORPORATION": "Sony",
+		"Nokia Solutions":  "Nokia Solutions",
+	} {
+		if got := db.CanonicalVendor(in); got != want {
+			t.Errorf("CanonicalVendor(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	if _, err := ReadAliases(strings.NewReader("Intel\n")); err == nil {
+		t.Errorf("want error for a line without a canonical name")
+	}
+}
+
+func TestDumpJSON_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, &BuildOptions{Format: EntriesV2}); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := Open(WithDir(dir), WithOverrides(map[string]string{"00:11:22:33:44:55": "Lab TV"}), WithPrecedence(LayerOverride, LayerRegistry))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	var dump bytes.Buffer
+	if err := db.DumpJSON(&dump); err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+	loaded, err := LoadJSON(bytes.NewReader(dump.Bytes()))
+	if err != nil {
+		t.Fatalf("load: %v\n%s", err, dump.String())
+	}
+	var again bytes.Buffer
+	if err := loaded.DumpJSON(&again); err != nil {
+		t.Fatalf("dump loaded: %v", err)
+	}
+	if again.String() != dump.String() {
+		t.Errorf("dump of loaded DB differs:\n%s\nwant:\n%s", again.String(), dump.String())
+	}
+	for _, mac := range []string{"0c:b4:a4:01:02:03", "00:11:22:33:44:55", "00:11:25:00:00:01", "00:00:5e:00:01:01"} {
+		want, _ := db.LookupRecord(mac)
+		if got, _ := loaded.LookupRecord(mac); got != want {
+			t.Errorf("LookupRecord(%q) = %+v, want %+v", mac, got, want)
+		}
+	}
+	if lines := strings.Count(dump.String(), "\n    {\"prefix\""); lines != db.Len()+1 {
+		t.Errorf("got %d entry and override lines, want one per entry and override", lines)
+	}
+
+	truncating, err := Open(WithDir(dir), WithTruncate(true))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	dump.Reset()
+	if err := truncating.DumpJSON(&dump); err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+	if loaded, err = LoadJSON(&dump); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if rec, err := loaded.LookupRecord("0c:b4:a4:xx:xx:xx"); err != nil || !rec.Truncated {
+		t.Errorf("loaded truncating DB: got %+v, %v; want a truncated match", rec, err)
+	}
+}
+
+func TestShortName(t *testing.T) {
+	for in, want := range map[string]string{
+		"Raspberry Pi Foundation": "Raspberr",
+		"Cisco Systems, Inc":      "Cisco",
+		"The Company":             "TheCompa",
+		"":                        "",
+	} {
+		if got := ShortName(in); got != want {
+			t.Errorf("ShortName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDB_All(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	var got []string
+	for p, v := range db.All() {
+		got = append(got, p+"="+v)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if strings.Join(got, ",") != "001122=Sony,001123=Acme" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestBuild_DatasetInfo(t *testing.T) {
+	dir := t.TempDir()
+	built := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	attr := SourceAttribution(SourceIEEE, []string{"ma-l"}, []string{"https://example.com/oui.csv"})
+	res, err := Build(strings.NewReader(testCSV), dir, &BuildOptions{Origin: "oui.csv", BuiltAt: built, Attribution: attr})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	got := db.Metadata().Attribution
+	if got.Name != "IEEE Registration Authority (MA-L)" || !slices.Equal(got.URLs, attr.URLs) || !got.RetrievedAt.Equal(built) {
+		t.Errorf("attribution = %+v, want IEEE MA-L from %v retrieved at %v", got, attr.URLs, built)
+	}
+	if v := db.Version(); len(v) != 12 {
+		t.Errorf("version = %q, want 12 hex digits", v)
+	}
+	if !db.BuiltAt().Equal(built) {
+		t.Errorf("built at = %v, want %v", db.BuiltAt(), built)
+	}
+	if db.Len() != res.Entries {
+		t.Errorf("len = %d, want %d", db.Len(), res.Entries)
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if err := db.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := db.BuiltAt().Unix(); got != 1700000000 {
+		t.Errorf("built at = %d, want SOURCE_DATE_EPOCH", got)
+	}
+}
+
+func TestBuild_WiresharkManuf(t *testing.T) {
+	manuf := "# Wireshark manuf\n" +
+		"00:00:0C\tCisco\tCisco Systems, Inc\n" +
+		"00:1B:C5:00:00:00/36\tConvergi\tConverging Systems Inc.\n" +
+		"70:B3:D5:10:00:00/28\tBlock\n" +
+		"01:80:C2:00:00:30/45\tOAM-Multicast-DA-Class-1\n"
+	dir := t.TempDir()
+	res, err := Build(strings.NewReader(manuf), dir, &BuildOptions{Source: SourceWireshark})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if res.Entries != 3 {
+		t.Errorf("entries = %d, want 3", res.Entries)
+	}
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for mac, want := range map[string]string{
+		"00:00:0c:01:02:03": "Cisco Systems",
+		"00:1b:c5:00:00:01": "Converging Systems",
+		"70:b3:d5:1a:00:00": "Block",
+	} {
+		if got, _ := db.Lookup(mac); got != want {
+			t.Errorf("%s: got %q, want %q", mac, got, want)
+		}
+	}
+}
+
+func TestBuild_NmapPrefixes(t *testing.T) {
+	in := "# nmap-mac-prefixes\n000000 Xerox\n0050C2000 T.L.S. Corp.\n12345 Too Short\n"
+	dir := t.TempDir()
+	res, err := Build(strings.NewReader(in), dir, &BuildOptions{Source: SourceNmap, KeepRawNames: true})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if res.Entries != 2 {
+		t.Errorf("entries = %d, want 2", res.Entries)
+	}
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got, _ := db.Lookup("00:50:c2:00:01:02"); got != "T.L.S. Corp." {
+		t.Errorf("got %q, want T.L.S. Corp.", got)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// benchResult holds the measurements of one workload.
+type benchResult struct {
+	name    string
+	ops     int // measured operations (lookups, or batches for the batch workload)
+	lookups int // individual lookups performed
+	total   time.Duration
+	lat     []time.Duration
+	allocs  uint64
+	bytes   uint64
+}
+
+// runBench implements `pg-oui bench`: it runs standardized lookup workloads
+// against the opened DB and prints latency and allocation statistics.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	dir := fs.String("dir", "", "data directory containing entries/vendors/vendors.index")
+	n := fs.Int("n", 100000, "lookups per workload")
+	batch := fs.Int("batch", 1000, "inputs per batch in the batch workload")
+	seed := fs.Uint64("seed", 1, "seed for workload generation")
+	langFlag(fs)
+	_ = fs.Parse(args)
+
+	if *n <= 0 || *batch <= 0 {
+		fmt.Fprintln(os.Stderr, "bench: -n and -batch must be positive")
+		os.Exit(exitUsage)
+	}
+
+	db, err := openDB(*dir)
+	if err != nil {
+		fail("open db", err)
+	}
+
+	rng := rand.New(rand.NewPCG(*seed, *seed))
+	hits, misses := sampleMACs(db, rng, 1024)
+	if len(hits) == 0 {
+		fmt.Fprintln(os.Stderr, "bench: no known OUIs found in dataset")
+		os.Exit(exitBadDataset)
+	}
+
+	mixed := make([]string, 0, len(hits)+len(misses))
+	mixed = append(mixed, hits...)
+	mixed = append(mixed, misses...)
+	rng.Shuffle(len(mixed), func(i, j int) { mixed[i], mixed[j] = mixed[j], mixed[i] })
+
+	results := []benchResult{
+		measure("random-hits", *n, 1, func(i int) { db.Lookup(hits[i%len(hits)]) }),
+		measure("misses", *n, 1, func(i int) { db.Lookup(misses[i%len(misses)]) }),
+		measure("batch", (*n+*batch-1) / *batch, *batch, func(i int) {
+			for j := 0; j < *batch; j++ {
+				db.Lookup(mixed[(i**batch+j)%len(mixed)])
+			}
+		}),
+	}
+
+	fmt.Printf(tr("backend: %s\nsamples: %d hits, %d misses\n\n"), "memory", len(hits), len(misses))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "%s\t%s\tns/op\tp50\tp90\tp99\tmax\tlookups/s\tallocs/op\tB/op\t\n", tr("workload"), tr("ops"))
+	for _, r := range results {
+		slices.Sort(r.lat)
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\t%v\t%v\t%.0f\t%d\t%d\t\n",
+			r.name, r.ops,
+			r.total.Nanoseconds()/int64(r.ops),
+			percentile(r.lat, 0.50), percentile(r.lat, 0.90), percentile(r.lat, 0.99), r.lat[len(r.lat)-1],
+			float64(r.lookups)/r.total.Seconds(),
+			r.allocs/uint64(r.ops), r.bytes/uint64(r.ops))
+	}
+	_ = tw.Flush()
+}
+
+// sampleMACs probes random prefixes until it has collected want known and
+// want unknown MACs, or the probe budget is exhausted.
+func sampleMACs(db *pg_oui.DB, rng *rand.Rand, want int) (hits, misses []string) {
+	const maxProbes = 1 << 22
+	for i := 0; i < maxProbes && (len(hits) < want || len(misses) < want); i++ {
+		mac := fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x",
+			rng.IntN(256), rng.IntN(256), rng.IntN(256), rng.IntN(256), rng.IntN(256), rng.IntN(256))
+		if _, ok := db.Lookup(mac); ok {
+			if len(hits) < want {
+				hits = append(hits, mac)
+			}
+		} else if len(misses) < want {
+			misses = append(misses, mac)
+		}
+	}
+	return hits, misses
+}
+
+// measure runs fn ops times, recording per-op latency and the allocations
+// made across the whole run.
+func measure(name string, ops, lookupsPerOp int, fn func(i int)) benchResult {
+	r := benchResult{name: name, ops: ops, lookups: ops * lookupsPerOp, lat: make([]time.Duration, ops)}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < ops; i++ {
+		t := time.Now()
+		fn(i)
+		r.lat[i] = time.Since(t)
+	}
+	r.total = time.Since(start)
+	runtime.ReadMemStats(&after)
+	r.allocs = after.Mallocs - before.Mallocs
+	r.bytes = after.TotalAlloc - before.TotalAlloc
+	return r
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// runEnrich implements `pg-oui enrich`: it copies a CSV file, appending the
+// vendor of the MAC in one column to every row.
+func runEnrich(args []string) {
+	fs := flag.NewFlagSet("enrich", flag.ExitOnError)
+	dir := fs.String("dir", "", "data directory containing entries/vendors/vendors.index")
+	input := fs.String("input", "-", "CSV file to read (- for stdin)")
+	output := fs.String("output", "-", "file to write the enriched CSV to (- for stdout)")
+	column := fs.String("mac-column", "1", "column holding the MAC: a 1-based number, or a name from the header row")
+	header := fs.Bool("header", false, "the first row is a header; a vendor column name is appended to it")
+	name := fs.String("vendor-column", "vendor", "name of the appended column in the header row")
+	comma := fs.String("delimiter", ",", "field delimiter, one character")
+	_ = fs.Parse(args)
+
+	if len([]rune(*comma)) != 1 {
+		fmt.Fprintln(os.Stderr, "enrich: -delimiter must be one character")
+		os.Exit(exitUsage)
+	}
+	col, err := strconv.Atoi(*column)
+	switch {
+	case err != nil:
+		// A column name: find it in the header row.
+		*header = true
+		col = 0
+	case col < 1:
+		fmt.Fprintln(os.Stderr, "enrich: -mac-column must be 1 or more")
+		os.Exit(exitUsage)
+	}
+
+	db, err := openDB(*dir)
+	if err != nil {
+		fail("open db", err)
+	}
+	in := os.Stdin
+	if *input != "-" {
+		if in, err = os.Open(*input); err != nil {
+			fail("enrich", err)
+		}
+		defer in.Close()
+	}
+	out := os.Stdout
+	if *output != "-" {
+		if out, err = os.Create(*output); err != nil {
+			fail("enrich", err)
+		}
+	}
+	if err := enrichCSV(db, in, out, []rune(*comma)[0], col, *column, *header, *name); err != nil {
+		fail("enrich", err)
+	}
+	if err := out.Close(); err != nil {
+		fail("enrich", err)
+	}
+}
+
+// enrichCSV copies CSV rows from r to w with the vendor of column col
+// (1-based; 0 looks up colName in the header) appended. Rows too short to
+// have the column get an empty vendor.
+func enrichCSV(db *pg_oui.DB, r io.Reader, w io.Writer, comma rune, col int, colName string, header bool, vendorName string) error {
+	cr := csv.NewReader(bufio.NewReaderSize(r, 64<<10))
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	bw := bufio.NewWriterSize(w, 64<<10)
+	cw := csv.NewWriter(bw)
+	cw.Comma = comma
+
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if line == 1 && header {
+			if col == 0 {
+				if col = slices.Index(rec, colName) + 1; col == 0 {
+					return fmt.Errorf("no column %q in header", colName)
+				}
+			}
+			rec = append(rec, vendorName)
+		} else {
+			var vendor string
+			if col <= len(rec) {
+				vendor, _ = db.Lookup(rec[col-1])
+			}
+			rec = append(rec, vendor)
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
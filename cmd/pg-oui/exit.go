@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// Exit codes shared by all subcommands, so scripts can branch on the kind of
+// failure. Flag parse errors exit with exitUsage via flag.ExitOnError.
+const (
+	exitOK         = 0
+	exitFailure    = 1 // unknown inputs with -strict, or any other failure
+	exitUsage      = 2
+	exitNoDataset  = 3
+	exitBadDataset = 4 // stale or corrupt dataset, or wrong vendors in selftest
+	exitNetwork    = 5
+)
+
+// errStaleDataset is returned by checkAge for a dataset older than -max-age.
+var errStaleDataset = errors.New("dataset is stale")
+
+// exitCode maps err to one of the exit codes above.
+func exitCode(err error) int {
+	var ue *url.Error
+	var ne net.Error
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, pg_oui.ErrDatasetNotFound):
+		return exitNoDataset
+	case errors.Is(err, pg_oui.ErrCorruptDataset), errors.Is(err, errStaleDataset):
+		return exitBadDataset
+	case errors.Is(err, pg_oui.ErrDownloadFailed), errors.As(err, &ue), errors.As(err, &ne):
+		return exitNetwork
+	}
+	return exitFailure
+}
+
+// fail prints "what: err" to stderr and exits with exitCode(err).
+func fail(what string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", what, err)
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runExport implements `pg-oui export`: it writes the loaded dataset to
+// stdout in another tool's format.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := fs.String("dir", "", "data directory containing entries/vendors/vendors.index")
+	format := fs.String("format", "nmap", "output format: nmap (nmap-mac-prefixes), sql (SQLite script), json (DumpJSON, for diffing), cisco-acl, iptables (ebtables commands) or pf rules, or dnsmasq or dhcpd config")
+	var queries []string
+	fs.Func("vendor", "only export OUIs of this vendor name or /regexp/ (repeatable)", func(s string) error {
+		queries = append(queries, s)
+		return nil
+	})
+	queryFile := fs.String("f", "", "read -vendor names or /regexp/s from this file, one per line")
+	action := fs.String("action", "deny", "what rules do with matching traffic: deny or permit")
+	aclName := fs.String("acl-name", "PG-OUI", "name of the cisco-acl access list")
+	tag := fs.String("tag", "", "dnsmasq tag or dhcpd class to assign (default: derived from each -vendor)")
+	_ = fs.Parse(args)
+
+	rules, ok := ruleFormats[*format]
+	if !ok && *format != "nmap" && *format != "sql" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "export: unknown -format %q (want nmap, sql, json, cisco-acl, iptables, pf, dnsmasq or dhcpd)\n", *format)
+		os.Exit(exitUsage)
+	}
+	if *action != "deny" && *action != "permit" {
+		fmt.Fprintf(os.Stderr, "export: unknown -action %q (want deny or permit)\n", *action)
+		os.Exit(exitUsage)
+	}
+	if *queryFile != "" {
+		fileQueries, err := readQueries(*queryFile)
+		if err != nil {
+			fail("read vendors", err)
+		}
+		queries = append(queries, fileQueries...)
+	}
+	if rules != nil && len(queries) == 0 {
+		fmt.Fprintf(os.Stderr, "export: -format %s needs -vendor or -f to select vendors\n", *format)
+		os.Exit(exitUsage)
+	}
+	if *format == "json" && len(queries) > 0 {
+		fmt.Fprintln(os.Stderr, "export: -format json dumps the whole DB and takes no -vendor or -f")
+		os.Exit(exitUsage)
+	}
+	db, err := openDB(*dir)
+	if err != nil {
+		fail("open db", err)
+	}
+	if *format == "json" {
+		if err := db.DumpJSON(os.Stdout); err != nil {
+			fail("export", err)
+		}
+		return
+	}
+
+	var results []reverseResult
+	var keep map[string]bool
+	for _, q := range queries {
+		res := reverseLookup(db, q)
+		if res.Error != "" {
+			fail("export", fmt.Errorf("%s: %s", q, res.Error))
+		}
+		if !res.Found {
+			fmt.Fprintf(os.Stderr, "no OUIs for %q\n", q)
+		}
+		results = append(results, res)
+		if keep == nil {
+			keep = make(map[string]bool)
+		}
+		for _, o := range res.OUIs {
+			keep[o] = true
+		}
+	}
+	all := func(yield func(string, string) bool) {
+		for prefix, vendor := range db.All() {
+			if (keep == nil || keep[prefix]) && !yield(prefix, vendor) {
+				return
+			}
+		}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	switch *format {
+	case "nmap":
+		fmt.Fprintln(w, "# MAC prefix to vendor mapping generated by pg-oui export")
+		for prefix, vendor := range all {
+			fmt.Fprintf(w, "%s %s\n", strings.ToUpper(prefix), vendor)
+		}
+	case "sql":
+		// Plain SQL keeps the CLI free of a database driver; load it with
+		// `sqlite3 oui.db < oui.sql`.
+		fmt.Fprintln(w, "BEGIN TRANSACTION;")
+		fmt.Fprintln(w, "CREATE TABLE oui (prefix TEXT PRIMARY KEY, bits INTEGER NOT NULL, vendor TEXT NOT NULL);")
+		for prefix, vendor := range all {
+			fmt.Fprintf(w, "INSERT INTO oui VALUES ('%s', %d, '%s');\n", prefix, len(prefix)*4, strings.ReplaceAll(vendor, "'", "''"))
+		}
+		fmt.Fprintln(w, "CREATE INDEX oui_vendor ON oui (vendor);")
+		fmt.Fprintln(w, "COMMIT;")
+	default:
+		rules(w, results, ruleOptions{permit: *action == "permit", aclName: *aclName, tag: *tag})
+	}
+	if err := w.Flush(); err != nil {
+		fail("export", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// lookupResult is the JSON record piped to hook commands, one per line.
+type lookupResult struct {
+	Input  string `json:"input"`
+	Vendor string `json:"vendor"`
+	Found  bool   `json:"found"`
+}
+
+// hook is a user-supplied command started once via `sh -c` that receives
+// lookup results as NDJSON on its stdin. Its stdout and stderr go to our
+// stderr so they never mix with lookup output.
+type hook struct {
+	command    string
+	missesOnly bool
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	enc        *json.Encoder
+	failed     bool
+}
+
+func startHook(command string, missesOnly bool) (*hook, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %q: %w", command, err)
+	}
+	return &hook{command: command, missesOnly: missesOnly, cmd: cmd, stdin: stdin, enc: json.NewEncoder(stdin)}, nil
+}
+
+func (h *hook) send(r lookupResult) {
+	if h.failed || (h.missesOnly && r.Found) {
+		return
+	}
+	if err := h.enc.Encode(r); err != nil {
+		fmt.Fprintf(os.Stderr, "hook %q: %v (disabled)\n", h.command, err)
+		h.failed = true
+	}
+}
+
+func (h *hook) close() error {
+	_ = h.stdin.Close()
+	if err := h.cmd.Wait(); err != nil {
+		return fmt.Errorf("hook %q: %w", h.command, err)
+	}
+	return nil
+}
+
+// sink consumes lookup results; hooks and webhooks are sinks.
+type sink interface {
+	send(r lookupResult)
+	close() error
+}
+
+// sinks fans each lookup result out to every configured sink.
+type sinks []sink
+
+// startHooks starts the post-lookup and on-miss commands that are non-empty.
+func startHooks(postLookupCmd, onMissCmd string) (sinks, error) {
+	var ss sinks
+	for _, c := range []struct {
+		command    string
+		missesOnly bool
+	}{{postLookupCmd, false}, {onMissCmd, true}} {
+		if c.command == "" {
+			continue
+		}
+		h, err := startHook(c.command, c.missesOnly)
+		if err != nil {
+			ss.close()
+			return nil, err
+		}
+		ss = append(ss, h)
+	}
+	return ss, nil
+}
+
+func (ss sinks) emit(input, vendor string, found bool) {
+	if len(ss) == 0 {
+		return
+	}
+	r := lookupResult{Input: strings.TrimSpace(input), Vendor: vendor, Found: found}
+	for _, s := range ss {
+		s.send(r)
+	}
+}
+
+// close flushes and stops every sink, reporting failures to stderr.
+func (ss sinks) close() {
+	for _, s := range ss {
+		if err := s.close(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+// lang selects the language of human-readable labels: table headers, status
+// words and summaries. Vendor names and machine-readable output (NDJSON,
+// JSON, tab-separated lookup results) are never translated.
+var lang = langFromEnv()
+
+// messages maps a language to translations of the English labels, which are
+// also the keys. Missing entries fall back to English.
+var messages = map[string]map[string]string{
+	"de": {
+		"time": "Zeit", "trigger": "Auslöser", "user@host": "Benutzer@Host", "entries": "Einträge", "source": "Quelle",
+		"no dataset updates recorded": "keine Datensatz-Aktualisierungen aufgezeichnet",
+		"workload":                    "Last", "ops": "Vorgänge",
+		"backend: %s\nsamples: %d hits, %d misses\n\n": "Backend: %s\nStichproben: %d Treffer, %d Fehlschläge\n\n",
+		"not in dataset":                   "nicht im Datensatz",
+		"want %q, got %q":                  "erwartet %q, erhalten %q",
+		"selftest: %d of %d checks failed": "Selbsttest: %d von %d Prüfungen fehlgeschlagen",
+	},
+	"es": {
+		"time": "hora", "trigger": "origen", "user@host": "usuario@host", "entries": "entradas", "source": "fuente",
+		"no dataset updates recorded": "no hay actualizaciones del conjunto de datos registradas",
+		"workload":                    "carga", "ops": "operaciones",
+		"backend: %s\nsamples: %d hits, %d misses\n\n": "backend: %s\nmuestras: %d aciertos, %d fallos\n\n",
+		"not in dataset":                   "no está en el conjunto de datos",
+		"want %q, got %q":                  "se esperaba %q, se obtuvo %q",
+		"selftest: %d of %d checks failed": "autoprueba: %d de %d comprobaciones fallaron",
+	},
+	"fr": {
+		"time": "heure", "trigger": "déclencheur", "user@host": "utilisateur@hôte", "entries": "entrées", "source": "source",
+		"no dataset updates recorded": "aucune mise à jour du jeu de données enregistrée",
+		"workload":                    "charge", "ops": "opérations",
+		"backend: %s\nsamples: %d hits, %d misses\n\n": "backend : %s\néchantillons : %d trouvés, %d absents\n\n",
+		"not in dataset":                   "absent du jeu de données",
+		"want %q, got %q":                  "attendu %q, obtenu %q",
+		"selftest: %d of %d checks failed": "autotest : %d vérifications sur %d en échec",
+	},
+}
+
+// tr returns the translation of the English label s for lang.
+func tr(s string) string {
+	if t, ok := messages[lang][s]; ok {
+		return t
+	}
+	return s
+}
+
+// langFlag registers -lang on fs, defaulting to the locale environment.
+func langFlag(fs *flag.FlagSet) {
+	fs.Func("lang", "language of human-readable labels, e.g. de, es, fr (default from LC_ALL, LC_MESSAGES or LANG)", func(v string) error {
+		lang = baseLang(v)
+		return nil
+	})
+}
+
+// langFromEnv follows the POSIX locale precedence.
+func langFromEnv() string {
+	for _, k := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(k); v != "" {
+			return baseLang(v)
+		}
+	}
+	return ""
+}
+
+// baseLang reduces a locale such as "de_DE.UTF-8" to "de".
+func baseLang(v string) string {
+	if i := strings.IndexAny(v, "_.@-"); i >= 0 {
+		v = v[:i]
+	}
+	return strings.ToLower(v)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// arpTable is the Linux neighbor table used when no -input is given.
+const arpTable = "/proc/net/arp"
+
+// inventoryHost is one neighbor: an address and its MAC.
+type inventoryHost struct {
+	Host string
+	MAC  string
+}
+
+// runInventory implements `pg-oui inventory`: an Ansible dynamic inventory
+// of the hosts in the neighbor table, grouped by vendor, so playbooks can
+// target e.g. vendor_raspberry_pi_trading. Ansible runs it with --list or
+// --host name.
+func runInventory(args []string) {
+	fs := flag.NewFlagSet("inventory", flag.ExitOnError)
+	dir := fs.String("dir", "", "data directory containing entries/vendors/vendors.index")
+	input := fs.String("input", "", "read \"host mac\" lines from this file (- for stdin) instead of "+arpTable)
+	_ = fs.Bool("list", true, "print the whole inventory (the default)")
+	host := fs.String("host", "", "print the variables of one host")
+	_ = fs.Parse(args)
+
+	db, err := openDB(*dir)
+	if err != nil {
+		fail("open db", err)
+	}
+	var hosts []inventoryHost
+	switch *input {
+	case "":
+		hosts, err = readARPTable(arpTable)
+	case "-":
+		hosts, err = readHostList(os.Stdin)
+	default:
+		var f *os.File
+		if f, err = os.Open(*input); err == nil {
+			hosts, err = readHostList(f)
+			f.Close()
+		}
+	}
+	if err != nil {
+		fail("read neighbors", err)
+	}
+
+	inv, hostvars := buildInventory(db, hosts)
+	var out any = inv
+	if *host != "" {
+		out = hostvars[*host]
+		if hostvars[*host] == nil {
+			out = map[string]string{}
+		}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		fail("inventory", err)
+	}
+}
+
+// buildInventory returns the inventory JSON object, with one vendor_* group
+// per vendor (vendor_unknown for unregistered MACs), and the MAC and vendor
+// of every host, which are also in its _meta.hostvars.
+func buildInventory(db *pg_oui.DB, hosts []inventoryHost) (map[string]any, map[string]map[string]string) {
+	inv := map[string]any{}
+	groups := map[string][]string{}
+	hostvars := map[string]map[string]string{}
+	for _, h := range hosts {
+		vendor, _ := db.Lookup(h.MAC)
+		g := "vendor_" + groupName(vendor)
+		if !slices.Contains(groups[g], h.Host) {
+			groups[g] = append(groups[g], h.Host)
+		}
+		hostvars[h.Host] = map[string]string{"mac": h.MAC, "vendor": vendor}
+	}
+	children := []string{}
+	for g, hs := range groups {
+		slices.Sort(hs)
+		inv[g] = map[string]any{"hosts": hs}
+		children = append(children, g)
+	}
+	slices.Sort(children)
+	inv["all"] = map[string]any{"children": children}
+	inv["_meta"] = map[string]any{"hostvars": hostvars}
+	return inv, hostvars
+}
+
+// groupName turns a vendor name into a valid Ansible group name suffix,
+// e.g. "raspberry_pi_trading"; "" becomes "unknown".
+func groupName(vendor string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(vendor) {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	if g := strings.TrimSuffix(b.String(), "_"); g != "" {
+		return g
+	}
+	return "unknown"
+}
+
+// readARPTable parses /proc/net/arp, skipping incomplete entries.
+func readARPTable(path string) ([]inventoryHost, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w (use -input on systems without %s)", err, path)
+	}
+	var hosts []inventoryHost
+	for i, line := range strings.Split(string(b), "\n") {
+		// IP address, HW type, Flags, HW address, Mask, Device
+		f := strings.Fields(line)
+		if i == 0 || len(f) < 4 || f[2] == "0x0" || f[3] == "00:00:00:00:00:00" {
+			continue
+		}
+		hosts = append(hosts, inventoryHost{Host: f[0], MAC: f[3]})
+	}
+	return hosts, nil
+}
+
+// readHostList reads "host mac" lines, skipping blank lines and # comments.
+func readHostList(r io.Reader) ([]inventoryHost, error) {
+	var hosts []inventoryHost
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			return nil, fmt.Errorf("line %d: want \"host mac\", got %q", n, line)
+		}
+		hosts = append(hosts, inventoryHost{Host: f[0], MAC: f[1]})
+	}
+	return hosts, sc.Err()
+}
@@ -7,58 +7,207 @@ import (
 	pg_oui "github.com/pre-history/pg-oui"
 	"io"
 	"os"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bench":
+			runBench(os.Args[2:])
+			return
+		case "stats":
+			runStats(os.Args[2:])
+			return
+		case "update":
+			runUpdate(os.Args[2:])
+			return
+		case "watch":
+			runWatch(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "selftest":
+			runSelftest(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "validate":
+			runValidate(os.Args[2:])
+			return
+		case "enrich":
+			runEnrich(os.Args[2:])
+			return
+		case "inventory":
+			runInventory(os.Args[2:])
+			return
+		case "schema":
+			runSchema(os.Args[2:])
+			return
+		case "pcap":
+			runPcap(os.Args[2:])
+			return
+		}
+	}
+
 	dir := flag.String("dir", "", "data directory containing entries/vendors/vendors.index")
+	workers := flag.Int("workers", 1, "number of lookup workers for stdin input (output order is preserved)")
+	flag.IntVar(workers, "parallel", 1, "alias for -workers")
+	buffered := flag.Bool("buffered", false, "buffer output instead of writing each line as soon as it is resolved; much faster for bulk input")
+	postLookupCmd := flag.String("post-lookup-cmd", "", "shell command that receives every lookup result as NDJSON on stdin")
+	onMissCmd := flag.String("on-miss-cmd", "", "shell command that receives unknown inputs as NDJSON on stdin")
+	webhookURL := flag.String("webhook", "", "POST lookup results as batched NDJSON to this URL")
+	webhookBatch := flag.Int("webhook-batch", 500, "records per webhook request")
+	webhookInterval := flag.Duration("webhook-interval", 5*time.Second, "maximum time a record waits before its batch is sent")
+	webhookRetries := flag.Int("webhook-retries", 3, "retries per webhook batch on network errors and 5xx responses")
+	debugListen := flag.String("debug-listen", "", "serve pprof and runtime memstats on this address (e.g. localhost:6060)")
+	strict := flag.Bool("strict", false, "exit 1 if any input is not found")
+	truncate := flag.Bool("truncate", false, "ignore what follows the hex digits of an input (e.g. redacted b8:27:eb:xx:xx:xx) instead of rejecting it")
+	reverse := flag.Bool("reverse", false, "print the OUIs of the vendors named by the arguments (or /regexp/s) instead of looking up MACs")
+	queryFile := flag.String("f", "", "with -reverse, read vendor names or /regexp/s from this file, one per line (- for stdin)")
+	maxAge := flag.Duration("max-age", 0, "exit 4 if the dataset was built longer ago than this (e.g. 2160h); 0 disables")
 	flag.Parse()
 
-	var opts []pg_oui.Option
-	if *dir != "" {
-		opts = append(opts, pg_oui.WithDir(*dir))
+	if *debugListen != "" {
+		startDebugListener(*debugListen)
 	}
 
-	db, err := pg_oui.Open(opts...)
+	db, err := openDB(*dir, pg_oui.WithTruncate(*truncate))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
-		os.Exit(2)
+		fail("open db", err)
 	}
-
-	args := flag.Args()
-	if len(args) > 0 {
-		for _, s := range args {
-			if v, ok := db.Lookup(s); ok {
-				fmt.Println(v)
-			} else {
-				fmt.Println("")
+	if err := checkAge(db, *maxAge); err != nil {
+		fail("open db", err)
+	}
+	if *reverse {
+		queries := flag.Args()
+		if *queryFile != "" {
+			fileQueries, err := readQueries(*queryFile)
+			if err != nil {
+				fail("read vendors", err)
 			}
+			queries = append(queries, fileQueries...)
+		}
+		if len(queries) == 0 {
+			fmt.Fprintln(os.Stderr, "usage: pg-oui -reverse [-strict] [-f file] [vendor|/regexp/ ...]")
+			os.Exit(exitUsage)
+		}
+		found, err := runReverse(db, queries)
+		if err != nil {
+			fail("reverse", err)
+		}
+		if *strict && !found {
+			os.Exit(exitFailure)
 		}
 		return
 	}
 
-	// Read from stdin, one per line
-	stat, _ := os.Stdin.Stat()
-	if (stat.Mode() & os.ModeCharDevice) != 0 {
-		fmt.Fprintln(os.Stderr, "usage: pg-oui [-dir path] <MAC-or-OUI> [...] | echo <MAC> | pg-oui [-dir path]")
-		os.Exit(1)
+	hs, err := startHooks(*postLookupCmd, *onMissCmd)
+	if err != nil {
+		fail("start hook", err)
+	}
+	if *webhookURL != "" {
+		if *webhookBatch <= 0 || *webhookInterval <= 0 {
+			fmt.Fprintln(os.Stderr, "-webhook-batch and -webhook-interval must be positive")
+			hs.close()
+			os.Exit(exitUsage)
+		}
+		hs = append(hs, startWebhook(*webhookURL, *webhookBatch, *webhookInterval, *webhookRetries))
+	}
+	args := flag.Args()
+	if len(args) == 0 {
+		// Read from stdin, one per line
+		if stat, _ := os.Stdin.Stat(); stat.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprintln(os.Stderr, "usage: pg-oui [-dir path] [-strict] <MAC-or-OUI> [...] | echo <MAC> | pg-oui [-dir path] | pg-oui -reverse [-f file] [vendor ...] | pg-oui bench|stats [-dir path] | pg-oui update -publish dir | pg-oui serve|watch|export|selftest|validate|enrich|inventory|pcap [-dir path] | pg-oui schema [name]")
+			hs.close()
+			os.Exit(exitUsage)
+		}
+	}
+
+	missed := false
+	err = lookupInput(db, args, *workers, *buffered, func(input, vendor string, found bool) {
+		missed = missed || !found
+		hs.emit(input, vendor, found)
+	})
+	hs.close()
+	if err != nil {
+		fail("lookup", err)
+	}
+	if *strict && missed {
+		os.Exit(exitFailure)
 	}
+}
 
-	r := bufio.NewReader(os.Stdin)
+// lookupInput prints the vendor of each arg, or of each stdin line when
+// there are no args, and passes every result to emit. Unless buffered, each
+// line is written as soon as it is resolved, so piping a live log through
+// pg-oui shows results immediately; with several workers, the lines read so
+// far are written once they are resolved.
+func lookupInput(db *pg_oui.DB, args []string, workers int, buffered bool, emit func(input, vendor string, found bool)) error {
+	if len(args) == 0 && workers > 1 {
+		return lookupParallel(db, os.Stdin, os.Stdout, workers, buffered, emit)
+	}
+	w := bufio.NewWriterSize(os.Stdout, 64<<10)
+	print := func(s string) error {
+		w.WriteString(s)
+		w.WriteByte('\n')
+		if buffered {
+			return nil
+		}
+		return w.Flush()
+	}
+	for _, s := range args {
+		v, ok := db.Lookup(s)
+		if err := print(v); err != nil {
+			return err
+		}
+		emit(s, v, ok)
+	}
+	if len(args) > 0 {
+		return w.Flush()
+	}
+
+	r := bufio.NewReaderSize(os.Stdin, 64<<10)
 	for {
 		line, err := r.ReadString('\n')
 		if len(line) > 0 {
-			if v, ok := db.Lookup(line); ok {
-				fmt.Println(v)
-			} else {
-				fmt.Println("")
+			v, ok := db.Lookup(line)
+			if err := print(v); err != nil {
+				return err
 			}
+			emit(line, v, ok)
 		}
 		if err == io.EOF {
-			break
+			return w.Flush()
 		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "read stdin: %v\n", err)
-			os.Exit(2)
+			return err
 		}
 	}
 }
+
+// openDB opens the dataset from dir, or from the default locations when dir is empty.
+func openDB(dir string, opts ...pg_oui.Option) (*pg_oui.DB, error) {
+	if dir != "" {
+		opts = append(opts, pg_oui.WithDir(dir))
+	}
+	return pg_oui.Open(opts...)
+}
+
+// checkAge returns an errStaleDataset error if maxAge is set and db was
+// built longer ago than that, or at an unknown time.
+func checkAge(db *pg_oui.DB, maxAge time.Duration) error {
+	if maxAge <= 0 {
+		return nil
+	}
+	built := db.BuiltAt()
+	if built.IsZero() {
+		return fmt.Errorf("%w: build time unknown", errStaleDataset)
+	}
+	if age := time.Since(built); age > maxAge {
+		return fmt.Errorf("%w: built %s ago (-max-age %s)", errStaleDataset, age.Round(time.Minute), maxAge)
+	}
+	return nil
+}
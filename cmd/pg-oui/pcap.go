@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pre-history/pg-oui/pcap"
+)
+
+// runPcap implements `pg-oui pcap`: it lists the unicast MACs in a capture
+// file with their vendors and frame counts.
+func runPcap(args []string) {
+	fs := flag.NewFlagSet("pcap", flag.ExitOnError)
+	dir := fs.String("dir", "", "data directory containing entries/vendors/vendors.index")
+	asJSON := fs.Bool("json", false, "print one JSON object per address instead of a table")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: pg-oui pcap [-dir path] [-json] file.pcap|file.pcapng|-")
+		os.Exit(exitUsage)
+	}
+	db, err := openDB(*dir)
+	if err != nil {
+		fail("open db", err)
+	}
+
+	var r io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fail("pcap", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	addrs, err := pcap.FromPcap(db, r)
+	if err != nil {
+		fail("pcap", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, a := range addrs {
+			_ = enc.Encode(a)
+		}
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "mac\tvendor\tsent\treceived")
+	for _, a := range addrs {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", a.MAC, a.Vendor, a.Sent, a.Received)
+	}
+	_ = tw.Flush()
+}
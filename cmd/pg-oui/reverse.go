@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// reverseResult is one answer of POST /v1/vendors/ouis.
+type reverseResult struct {
+	Query string   `json:"query"`
+	OUIs  []string `json:"ouis"`
+	Found bool     `json:"found"`
+	Error string   `json:"error,omitempty"`
+}
+
+// reverseLookup returns the OUIs of the vendor named query (ignoring case),
+// or of every vendor matching the regexp if query is written /regexp/.
+func reverseLookup(db *pg_oui.DB, query string) reverseResult {
+	res := reverseResult{Query: query, OUIs: []string{}}
+	var ouis []string
+	var err error
+	if len(query) >= 2 && query[0] == '/' && query[len(query)-1] == '/' {
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(query[1 : len(query)-1]); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		ouis, err = db.OUIsForVendorRegexp(re)
+	} else {
+		ouis, err = db.OUIsForVendor(query)
+	}
+	if err == nil {
+		res.OUIs, res.Found = ouis, true
+	}
+	return res
+}
+
+// readQueries reads one vendor name or /regexp/ per line from path ("-" for
+// stdin), skipping blank lines and # comments.
+func readQueries(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var queries []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && line[0] != '#' {
+			queries = append(queries, line)
+		}
+	}
+	return queries, sc.Err()
+}
+
+// runReverse prints the OUIs of each query, one per line, and reports
+// queries without any on stderr. It returns whether all were found.
+func runReverse(db *pg_oui.DB, queries []string) (bool, error) {
+	all := true
+	for _, q := range queries {
+		res := reverseLookup(db, q)
+		if res.Error != "" {
+			return false, fmt.Errorf("%s: %s", q, res.Error)
+		}
+		if !res.Found {
+			fmt.Fprintf(os.Stderr, "no OUIs for %q\n", q)
+			all = false
+		}
+		for _, o := range res.OUIs {
+			fmt.Println(o)
+		}
+	}
+	return all, nil
+}
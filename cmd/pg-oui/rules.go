@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ruleOptions are the export flags the rule formats use.
+type ruleOptions struct {
+	permit  bool   // allow matching traffic instead of blocking it
+	aclName string // cisco-acl access list name
+	tag     string // DHCP tag or class name; "" derives one per query
+}
+
+// ruleFormats write MAC prefix match rules for the OUIs in results, one
+// comment naming the query before each group.
+var ruleFormats = map[string]func(w io.Writer, results []reverseResult, o ruleOptions){
+	"cisco-acl": ciscoRules,
+	"iptables":  ebtablesRules,
+	"pf":        pfRules,
+	"dnsmasq":   dnsmasqRules,
+	"dhcpd":     dhcpdRules,
+}
+
+// prefixMAC returns the first MAC address of prefix (6, 7 or 9 hex digits)
+// and its mask, both as 12 hex digits.
+func prefixMAC(prefix string) (mac, mask string) {
+	return prefix + strings.Repeat("0", 12-len(prefix)), strings.Repeat("f", len(prefix)) + strings.Repeat("0", 12-len(prefix))
+}
+
+// colonMAC formats 12 hex digits as aa:bb:cc:dd:ee:ff.
+func colonMAC(h string) string {
+	return h[0:2] + ":" + h[2:4] + ":" + h[4:6] + ":" + h[6:8] + ":" + h[8:10] + ":" + h[10:12]
+}
+
+// ciscoRules writes an extended MAC access list. Cisco masks are wildcards
+// (set bits are ignored), in dotted groups of four hex digits. A deny list
+// ends with "permit any any" so other hosts keep working.
+func ciscoRules(w io.Writer, results []reverseResult, o ruleOptions) {
+	dotted := func(h string) string { return h[0:4] + "." + h[4:8] + "." + h[8:12] }
+	verb := "deny"
+	if o.permit {
+		verb = "permit"
+	}
+	fmt.Fprintf(w, "mac access-list extended %s\n", o.aclName)
+	for _, r := range results {
+		fmt.Fprintf(w, " remark %s\n", r.Query)
+		for _, p := range r.OUIs {
+			mac, _ := prefixMAC(p)
+			wildcard := strings.Repeat("0", len(p)) + strings.Repeat("f", 12-len(p))
+			fmt.Fprintf(w, " %s %s %s any\n", verb, dotted(mac), dotted(wildcard))
+		}
+	}
+	if !o.permit {
+		fmt.Fprintln(w, " permit any any")
+	}
+}
+
+// ebtablesRules writes ebtables commands: iptables' mac match only compares
+// whole addresses, so prefixes are matched on the bridge layer, which takes
+// a mask.
+func ebtablesRules(w io.Writer, results []reverseResult, o ruleOptions) {
+	target := "DROP"
+	if o.permit {
+		target = "ACCEPT"
+	}
+	for _, r := range results {
+		fmt.Fprintf(w, "# %s\n", r.Query)
+		for _, p := range r.OUIs {
+			mac, mask := prefixMAC(p)
+			fmt.Fprintf(w, "ebtables -A INPUT -s %s/%s -j %s\n", colonMAC(mac), colonMAC(mask), target)
+		}
+	}
+}
+
+// pfRules writes FreeBSD pf layer 2 (ether) rules.
+func pfRules(w io.Writer, results []reverseResult, o ruleOptions) {
+	verb := "block"
+	if o.permit {
+		verb = "pass"
+	}
+	for _, r := range results {
+		fmt.Fprintf(w, "# %s\n", r.Query)
+		for _, p := range r.OUIs {
+			mac, _ := prefixMAC(p)
+			fmt.Fprintf(w, "ether %s in quick from %s/%d\n", verb, colonMAC(mac), len(p)*4)
+		}
+	}
+}
+
+// bytePrefixes returns prefix as whole-byte prefixes in aa:bb:cc form, for
+// formats that match MACs a byte at a time: a 7 or 9 digit prefix becomes
+// the 16 byte prefixes one digit longer.
+func bytePrefixes(prefix string) []string {
+	colon := func(h string) string {
+		var b strings.Builder
+		for i := 0; i < len(h); i += 2 {
+			if i > 0 {
+				b.WriteByte(':')
+			}
+			b.WriteString(h[i : i+2])
+		}
+		return b.String()
+	}
+	if len(prefix)%2 == 0 {
+		return []string{colon(prefix)}
+	}
+	out := make([]string, 16)
+	for i := range out {
+		out[i] = colon(prefix + strconv.FormatInt(int64(i), 16))
+	}
+	return out
+}
+
+// ruleTag returns o.tag, or a name derived from the vendor query, e.g.
+// "raspberry-pi-trading" for "Raspberry Pi Trading".
+func ruleTag(o ruleOptions, query string) string {
+	if o.tag != "" {
+		return o.tag
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(query) {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	if t := strings.TrimSuffix(b.String(), "-"); t != "" {
+		return t
+	}
+	return "pg-oui"
+}
+
+// dnsmasqRules writes dhcp-mac lines setting a tag per vendor query.
+func dnsmasqRules(w io.Writer, results []reverseResult, o ruleOptions) {
+	for _, r := range results {
+		fmt.Fprintf(w, "# %s\n", r.Query)
+		tag := ruleTag(o, r.Query)
+		for _, p := range r.OUIs {
+			for _, b := range bytePrefixes(p) {
+				fmt.Fprintf(w, "dhcp-mac=set:%s,%s%s\n", tag, b, strings.Repeat(":*", 6-(len(b)+1)/3))
+			}
+		}
+	}
+}
+
+// dhcpdRules writes one ISC dhcpd class per vendor query, matching the
+// hardware address after its type byte.
+func dhcpdRules(w io.Writer, results []reverseResult, o ruleOptions) {
+	for _, r := range results {
+		if len(r.OUIs) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "# %s\n", r.Query)
+		fmt.Fprintf(w, "class %q {\n", ruleTag(o, r.Query))
+		var conds []string
+		for _, p := range r.OUIs {
+			for _, b := range bytePrefixes(p) {
+				conds = append(conds, fmt.Sprintf("substring(hardware, 1, %d) = %s", (len(b)+1)/3, b))
+			}
+		}
+		fmt.Fprintf(w, "  match if %s;\n}\n", strings.Join(conds, "\n    or "))
+	}
+}
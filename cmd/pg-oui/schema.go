@@ -0,0 +1,60 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+)
+
+// schemaVersion is the version in the $id of every schema. Fields may be
+// added to an output within a version; removing, renaming or retyping one
+// needs a new version.
+const schemaVersion = "v1"
+
+// schemas holds the JSON Schemas of the CLI's JSON outputs, one file per
+// output, named as `pg-oui schema` lists them.
+//
+//go:embed schemas/*.json
+var schemas embed.FS
+
+// runSchema implements `pg-oui schema [name]`: it lists the JSON outputs
+// with a schema, or prints the schema of one.
+func runSchema(args []string) {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: pg-oui schema [name]")
+		os.Exit(exitUsage)
+	}
+	if len(args) == 1 {
+		b, err := schemas.ReadFile("schemas/" + args[0] + ".json")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "schema: unknown output %q (see pg-oui schema)\n", args[0])
+			os.Exit(exitUsage)
+		}
+		os.Stdout.Write(b)
+		return
+	}
+	files, err := schemas.ReadDir("schemas")
+	if err != nil {
+		fail("schema", err)
+	}
+	fmt.Printf("JSON Schemas %s (print one with pg-oui schema name):\n", schemaVersion)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range files {
+		b, err := schemas.ReadFile(path.Join("schemas", f.Name()))
+		if err != nil {
+			fail("schema", err)
+		}
+		var s struct {
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal(b, &s); err != nil {
+			fail("schema "+f.Name(), err)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", strings.TrimSuffix(f.Name(), ".json"), s.Title)
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// wellKnown are long-standing MA-L assignments whose vendor names are not
+// expected to change. want is matched case-insensitively as a substring so
+// name simplification does not matter.
+var wellKnown = []struct{ oui, want string }{
+	{"B8:27:EB", "Raspberry Pi"},
+	{"DC:A6:32", "Raspberry Pi"},
+	{"00:1B:21", "Intel"},
+	{"00:02:B3", "Intel"},
+	{"24:0A:C4", "Espressif"},
+	{"30:AE:A4", "Espressif"},
+	{"00:03:93", "Apple"},
+	{"F0:18:98", "Apple"},
+	{"00:00:0C", "Cisco"},
+	{"00:50:56", "VMware"},
+}
+
+// runSelftest implements `pg-oui selftest`: it resolves well-known OUIs and
+// exits non-zero if any resolves to the wrong vendor, which catches index or
+// vendor ID mistakes in a dataset before it ships.
+func runSelftest(args []string) {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	dir := fs.String("dir", "", "data directory containing entries/vendors/vendors.index")
+	allowMissing := fs.Bool("allow-missing", false, "pass OUIs absent from the dataset (for filtered datasets)")
+	langFlag(fs)
+	_ = fs.Parse(args)
+
+	db, err := openDB(*dir)
+	if err != nil {
+		fail("open db", err)
+	}
+
+	failed := 0
+	for _, c := range wellKnown {
+		got, err := db.LookupErr(c.oui)
+		switch {
+		case errors.Is(err, pg_oui.ErrNotFound) && *allowMissing:
+			fmt.Printf("skip  %s  %s\n", c.oui, tr("not in dataset"))
+		case err != nil:
+			failed++
+			fmt.Printf("FAIL  %s  want %q: %v\n", c.oui, c.want, err)
+		case !strings.Contains(strings.ToLower(got), strings.ToLower(c.want)):
+			failed++
+			fmt.Printf("FAIL  %s  "+tr("want %q, got %q")+"\n", c.oui, c.want, got)
+		default:
+			fmt.Printf("ok    %s  %s\n", c.oui, got)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, tr("selftest: %d of %d checks failed")+"\n", failed, len(wellKnown))
+		os.Exit(exitBadDataset)
+	}
+}
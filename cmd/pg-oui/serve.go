@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// batchRequest is the body of POST /v1/lookup.
+type batchRequest struct {
+	MACs []string `json:"macs"`
+}
+
+// batchResponse answers POST /v1/lookup, one result per input in order.
+type batchResponse struct {
+	Results []lookupResult `json:"results"`
+}
+
+// reverseRequest is the body of POST /v1/vendors/ouis: vendor names,
+// compared case-insensitively, or regexps written /regexp/.
+type reverseRequest struct {
+	Vendors []string `json:"vendors"`
+}
+
+// reverseResponse answers POST /v1/vendors/ouis, one result per query in
+// order.
+type reverseResponse struct {
+	Results []reverseResult `json:"results"`
+}
+
+// aboutResponse answers GET /v1/about with the dataset's provenance.
+type aboutResponse struct {
+	Version     string             `json:"version,omitempty"`
+	BuiltAt     time.Time          `json:"built_at,omitzero"`
+	Entries     int                `json:"entries"`
+	Vendors     int                `json:"vendors"`
+	Attribution pg_oui.Attribution `json:"attribution,omitzero"`
+}
+
+// updateSlotResponse answers GET /v1/update-slot with how long the client
+// should wait before downloading the registries.
+type updateSlotResponse struct {
+	WaitSeconds float64 `json:"wait_seconds"`
+}
+
+// updateScheduler hands out download slots spacing apart, so agents using
+// pg_oui.WithUpdateScheduler fetch from upstream one after another.
+type updateScheduler struct {
+	spacing time.Duration
+	mu      sync.Mutex
+	next    time.Time // start of the next free slot
+}
+
+// slot reserves the next free slot and returns how long after now it starts.
+func (s *updateScheduler) slot(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next.Before(now) {
+		s.next = now
+	}
+	wait := s.next.Sub(now)
+	s.next = s.next.Add(s.spacing)
+	return wait
+}
+
+// runServe implements `pg-oui serve`: an HTTP API over the in-memory DB so
+// services can share one dataset instead of shipping data files.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dir := fs.String("dir", "", "data directory containing entries/vendors/vendors.index")
+	listen := fs.String("listen", ":8080", "address to listen on")
+	maxBatch := fs.Int("max-batch", 10000, "maximum MACs per POST /v1/lookup request")
+	reload := fs.Duration("reload-interval", 0, "check the data files this often and reload them when they change (0 disables)")
+	changeHook := fs.String("change-webhook", "", "POST prefixes whose vendor changed on reload to this URL as NDJSON")
+	trace := fs.Bool("trace", false, "log a JSON trace event per lookup to stderr, tagged with the request's X-Request-ID")
+	debugListen := fs.String("debug-listen", "", "serve pprof and runtime memstats on this address (e.g. localhost:6060)")
+	maxAge := fs.Duration("max-age", 0, "exit 4 at startup if the dataset was built longer ago than this (e.g. 2160h); 0 disables")
+	updateSpacing := fs.Duration("update-spacing", 0, "serve GET /v1/update-slot, handing out auto-update download slots this far apart (0 disables)")
+	_ = fs.Parse(args)
+	if *debugListen != "" {
+		startDebugListener(*debugListen)
+	}
+
+	var opts []pg_oui.Option
+	if *reload > 0 {
+		opts = append(opts, pg_oui.WithWatch(*reload), pg_oui.WithOnReloadError(func(err error) {
+			fmt.Fprintf(os.Stderr, "reload dataset: %v\n", err)
+		}))
+	}
+	if *changeHook != "" {
+		opts = append(opts, pg_oui.WithOnChange(notifyChanges(*changeHook)))
+	}
+	db, err := openDB(*dir, opts...)
+	if err != nil {
+		fail("open db", err)
+	}
+	if err := checkAge(db, *maxAge); err != nil {
+		fail("open db", err)
+	}
+
+	var tracer *slog.Logger
+	if *trace {
+		tracer = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+	var slots *updateScheduler
+	if *updateSpacing > 0 {
+		slots = &updateScheduler{spacing: *updateSpacing}
+	}
+	srv := &http.Server{Addr: *listen, Handler: newServeMux(db, *maxBatch, tracer, slots), ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "serving on %s\n", *listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fail("serve", err)
+	}
+}
+
+// notifyChanges returns a change handler that logs the number of changed
+// prefixes and POSTs them to url, one VendorChange per NDJSON line.
+func notifyChanges(url string) func([]pg_oui.VendorChange) {
+	w := &webhook{url: url, retries: 3, client: &http.Client{Timeout: 30 * time.Second}}
+	return func(changes []pg_oui.VendorChange) {
+		fmt.Fprintf(os.Stderr, "dataset reloaded: %d prefixes changed\n", len(changes))
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, c := range changes {
+			_ = enc.Encode(c)
+		}
+		if err := w.post(buf.Bytes()); err != nil {
+			fmt.Fprintf(os.Stderr, "change webhook: %v\n", err)
+		}
+	}
+}
+
+// newServeMux returns the API handlers. With a non-nil tracer every lookup
+// is logged with its request ID, see lookupTraced; with non-nil slots it
+// serves GET /v1/update-slot.
+func newServeMux(db *pg_oui.DB, maxBatch int, tracer *slog.Logger, slots *updateScheduler) *http.ServeMux {
+	mux := http.NewServeMux()
+	if slots != nil {
+		mux.HandleFunc("GET /v1/update-slot", func(w http.ResponseWriter, r *http.Request) {
+			respondJSON(w, http.StatusOK, updateSlotResponse{WaitSeconds: slots.slot(time.Now()).Seconds()})
+		})
+	}
+	mux.HandleFunc("GET /v1/lookup/{mac}", func(w http.ResponseWriter, r *http.Request) {
+		mac := r.PathValue("mac")
+		v, err := lookupTraced(db, tracer, w, r, mac)
+		status := http.StatusOK
+		switch {
+		case errors.Is(err, pg_oui.ErrInvalidMAC):
+			status = http.StatusBadRequest
+		case err != nil:
+			status = http.StatusNotFound
+		}
+		respondJSON(w, status, lookupResult{Input: mac, Vendor: v, Found: err == nil})
+	})
+	mux.HandleFunc("GET /v1/about", func(w http.ResponseWriter, r *http.Request) {
+		m := db.Metadata()
+		respondJSON(w, http.StatusOK, aboutResponse{Version: db.Version(), BuiltAt: db.BuiltAt(), Entries: m.Entries, Vendors: m.Vendors, Attribution: m.Attribution})
+	})
+	mux.HandleFunc("POST /v1/lookup", func(w http.ResponseWriter, r *http.Request) {
+		var req batchRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<20)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.MACs) > maxBatch {
+			http.Error(w, fmt.Sprintf("too many MACs (%d > %d)", len(req.MACs), maxBatch), http.StatusRequestEntityTooLarge)
+			return
+		}
+		resp := batchResponse{Results: make([]lookupResult, len(req.MACs))}
+		if tracer != nil {
+			for i, mac := range req.MACs {
+				v, err := lookupTraced(db, tracer, w, r, mac)
+				resp.Results[i] = lookupResult{Input: mac, Vendor: v, Found: err == nil}
+			}
+		} else {
+			for i, res := range db.LookupAll(req.MACs) {
+				resp.Results[i] = lookupResult{Input: req.MACs[i], Vendor: res.Vendor, Found: res.OK}
+			}
+		}
+		respondJSON(w, http.StatusOK, resp)
+	})
+	mux.HandleFunc("POST /v1/vendors/ouis", func(w http.ResponseWriter, r *http.Request) {
+		var req reverseRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<20)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Vendors) > maxBatch {
+			http.Error(w, fmt.Sprintf("too many vendors (%d > %d)", len(req.Vendors), maxBatch), http.StatusRequestEntityTooLarge)
+			return
+		}
+		resp := reverseResponse{Results: make([]reverseResult, len(req.Vendors))}
+		for i, q := range req.Vendors {
+			resp.Results[i] = reverseLookup(db, q)
+		}
+		respondJSON(w, http.StatusOK, resp)
+	})
+	return mux
+}
+
+// lookupTraced resolves mac and, if tracer is set, logs the input, its
+// normalized form, the outcome and the duration under the request's
+// X-Request-ID, generating one (and echoing it in the response) if the
+// client sent none.
+func lookupTraced(db *pg_oui.DB, tracer *slog.Logger, w http.ResponseWriter, r *http.Request, mac string) (string, error) {
+	if tracer == nil {
+		return db.LookupErr(mac)
+	}
+	id := r.Header.Get("X-Request-ID")
+	if id == "" {
+		var b [8]byte
+		_, _ = rand.Read(b[:])
+		id = hex.EncodeToString(b[:])
+		r.Header.Set("X-Request-ID", id)
+	}
+	w.Header().Set("X-Request-ID", id)
+
+	start := time.Now()
+	v, err := db.LookupErr(mac)
+	dur := time.Since(start)
+	norm, _ := pg_oui.Normalize(mac)
+	// backend is where the dataset was loaded from (dir, fs, json or the
+	// WithFallback source that answered); lookups are served from memory
+	// without a result cache, so there is never a cache hit.
+	attrs := []any{"request_id", id, "input", mac, "normalized", norm, "backend", db.Metadata().Source, "cache_hit", false, "vendor", v, "found", err == nil, "duration", dur}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+	}
+	tracer.Info("lookup", attrs...)
+	return v, err
+}
+
+func respondJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
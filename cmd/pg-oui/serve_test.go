@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpdateScheduler_Slot(t *testing.T) {
+	s := &updateScheduler{spacing: time.Minute}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, want := range []time.Duration{0, time.Minute, 2 * time.Minute} {
+		if got := s.slot(now); got != want {
+			t.Errorf("slot %d: got %s, want %s", i, got, want)
+		}
+	}
+	// Slots in the past are not handed out.
+	if got := s.slot(now.Add(time.Hour)); got != 0 {
+		t.Errorf("after an idle hour: got %s, want 0", got)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// runStats implements `pg-oui stats`: it prints the latest dataset update,
+// or the full update log with -history.
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	dir := fs.String("dir", "", "data directory containing the update log (default: PG_OUI_DATA_DIR or user cache dir)")
+	history := fs.Bool("history", false, "print every recorded dataset update, oldest first")
+	asJSON := fs.Bool("json", false, "print one JSON object per update (see pg-oui schema stats)")
+	langFlag(fs)
+	_ = fs.Parse(args)
+
+	recs, err := pg_oui.ReadUpdateHistory(*dir)
+	if err != nil {
+		fail("stats", err)
+	}
+	if len(recs) == 0 {
+		if !*asJSON {
+			fmt.Println(tr("no dataset updates recorded"))
+		}
+		return
+	}
+	if !*history {
+		recs = recs[len(recs)-1:]
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range recs {
+			_ = enc.Encode(r)
+		}
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\tsha256\t%s\n", tr("time"), tr("trigger"), tr("user@host"), tr("entries"), tr("source"))
+	for _, r := range recs {
+		fmt.Fprintf(tw, "%s\t%s\t%s@%s\t%d -> %d\t%.12s\t%s\n",
+			r.Time.Format(time.RFC3339), r.Trigger, r.User, r.Host, r.PreEntries, r.PostEntries, r.SHA256, r.Source)
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// Files written by `pg-oui update -publish` next to the dataset.
+const (
+	metadataName   = "metadata.json"
+	provenanceName = "provenance.json"
+	checksumsName  = "SHA256SUMS"
+)
+
+// publishMetadata describes the published dataset.
+type publishMetadata struct {
+	Format     string   `json:"format"`
+	Entries    int      `json:"entries"`
+	Vendors    int      `json:"vendors"`
+	Registries []string `json:"registries"`
+}
+
+// publishSource records one input CSV and its checksum.
+type publishSource struct {
+	URI    string `json:"uri"`
+	SHA256 string `json:"sha256"`
+	Bytes  int    `json:"bytes"`
+}
+
+// publishProvenance records how the dataset was produced. It holds no wall
+// clock time so repeated runs over the same sources are byte-identical;
+// SOURCE_DATE_EPOCH is copied through when set.
+type publishProvenance struct {
+	Builder         string          `json:"builder"`
+	GoVersion       string          `json:"go_version"`
+	SourceDateEpoch string          `json:"source_date_epoch,omitempty"`
+	Sources         []publishSource `json:"sources"`
+}
+
+// runUpdate implements `pg-oui update -publish dir`: it downloads (or reads)
+// the registry CSVs and writes the dataset, metadata, provenance and
+// checksums to dir in one deterministic step.
+func runUpdate(args []string) {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	publish := fs.String("publish", "", "directory to write the dataset, metadata.json, provenance.json and SHA256SUMS to")
+	registries := fs.String("registries", "ma-l,ma-m,ma-s", "comma-separated IEEE registries to include: ma-l, ma-m, ma-s, cid")
+	sources := fs.String("sources", "", "comma-separated local registry CSVs to use instead of downloading")
+	format := fs.String("entries-format", pg_oui.EntriesV1, "entries file format: v1 or v2")
+	_ = fs.Parse(args)
+
+	if *publish == "" {
+		fmt.Fprintln(os.Stderr, "usage: pg-oui update -publish dir [-registries list] [-sources files] [-entries-format v1|v2]")
+		os.Exit(exitUsage)
+	}
+	if err := publishDataset(*publish, splitList(*registries), splitList(*sources), *format); err != nil {
+		fail("update", err)
+	}
+}
+
+func publishDataset(dir string, registries, sources []string, format string) error {
+	// dataset.json is in SHA256SUMS, so its times must not come from the clock.
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	sec, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return fmt.Errorf("-publish needs SOURCE_DATE_EPOCH (seconds since 1970) for a reproducible build time, got %q", epoch)
+	}
+	built := time.Unix(sec, 0).UTC()
+
+	uris := sources
+	if len(uris) == 0 {
+		if uris, err = pg_oui.RegistryURLs(registries); err != nil {
+			return err
+		}
+	}
+
+	var csv bytes.Buffer
+	prov := publishProvenance{Builder: "pg-oui update", GoVersion: runtime.Version(), SourceDateEpoch: epoch}
+	for _, u := range uris {
+		b, err := readSource(u)
+		if err != nil {
+			return fmt.Errorf("%s: %w", u, err)
+		}
+		sum := sha256.Sum256(b)
+		prov.Sources = append(prov.Sources, publishSource{URI: u, SHA256: hex.EncodeToString(sum[:]), Bytes: len(b)})
+		csv.Write(b)
+		csv.WriteByte('\n')
+	}
+
+	opts := &pg_oui.BuildOptions{Registries: registries, Format: format, Origin: strings.Join(uris, " "), Attribution: pg_oui.SourceAttribution(pg_oui.SourceIEEE, registries, uris), BuiltAt: built}
+	opts.Attribution.RetrievedAt = built
+	res, err := pg_oui.Build(&csv, dir, opts)
+	if err != nil {
+		return fmt.Errorf("build: %w", err)
+	}
+	if format == "" {
+		format = pg_oui.EntriesV1
+	}
+	meta := publishMetadata{Format: format, Entries: res.Entries, Vendors: res.Vendors, Registries: registries}
+	if err := writeJSON(filepath.Join(dir, metadataName), meta); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(dir, provenanceName), prov); err != nil {
+		return err
+	}
+	return writeChecksums(dir, []string{"entries", "vendors", "vendors.index", pg_oui.DatasetInfoName, metadataName, provenanceName})
+}
+
+// readSource returns the contents of an http(s) URL or a local file.
+func readSource(uri string) ([]byte, error) {
+	if !strings.HasPrefix(uri, "http://") && !strings.HasPrefix(uri, "https://") {
+		return os.ReadFile(uri)
+	}
+	cl := &http.Client{Timeout: time.Minute}
+	resp, err := cl.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func writeJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// writeChecksums writes SHA256SUMS for names in dir, in sha256sum format.
+func writeChecksums(dir string, names []string) error {
+	var out bytes.Buffer
+	for _, n := range slices.Sorted(slices.Values(names)) {
+		b, err := os.ReadFile(filepath.Join(dir, n))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&out, "%x  %s\n", sha256.Sum256(b), n)
+	}
+	return os.WriteFile(filepath.Join(dir, checksumsName), out.Bytes(), 0o644)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const publishCSV = "Registry,Assignment,Organization Name,Organization Address\n" +
+	"MA-L,001122,Sony Corporation,Addr\n" +
+	"MA-L,0CB4A4,Nokia Solutions and Networks,Addr\n"
+
+func TestPublishDataset_Reproducible(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "oui.csv")
+	if err := os.WriteFile(src, []byte(publishCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	var sums [2][]byte
+	for i := range sums {
+		dir := t.TempDir()
+		if err := publishDataset(dir, []string{"MA-L"}, []string{src}, ""); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+		b, err := os.ReadFile(filepath.Join(dir, checksumsName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sums[i] = b
+		if i == 0 {
+			time.Sleep(1100 * time.Millisecond) // a wall-clock time would differ now
+		}
+	}
+	if !bytes.Equal(sums[0], sums[1]) {
+		t.Errorf("SHA256SUMS differ:\n%s\n%s", sums[0], sums[1])
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	if err := publishDataset(t.TempDir(), []string{"MA-L"}, []string{src}, ""); err == nil {
+		t.Error("want an error without SOURCE_DATE_EPOCH")
+	}
+}
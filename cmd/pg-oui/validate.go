@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// runValidate implements `pg-oui validate`: it checks the dataset and lists
+// every problem found, exiting with exitBadDataset if there are any or the
+// dataset is older than -max-age.
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	dir := fs.String("dir", "", "data directory containing entries/vendors/vendors.index")
+	thorough := fs.Bool("thorough", false, "also check every entry's prefix and vendor ID, and report duplicate OUIs")
+	maxAge := fs.Duration("max-age", 0, "also fail if the dataset was built longer ago than this (e.g. 2160h); 0 disables")
+	_ = fs.Parse(args)
+
+	db, err := openDB(*dir)
+	if err != nil {
+		fail("open db", err)
+	}
+	level := pg_oui.ValidateFast
+	if *thorough {
+		level = pg_oui.ValidateThorough
+	}
+	var verr *pg_oui.ValidationError
+	if err := db.Validate(level); errors.As(err, &verr) {
+		for _, p := range verr.Problems {
+			fmt.Println(p)
+		}
+		fail("validate", err)
+	}
+	if err := checkAge(db, *maxAge); err != nil {
+		fail("validate", err)
+	}
+	fmt.Println("ok")
+}
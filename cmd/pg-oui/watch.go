@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// linkEvent reports a network interface appearing or disappearing.
+type linkEvent struct {
+	added bool
+	name  string
+	hw    net.HardwareAddr
+}
+
+// watchEvent is one line of `pg-oui watch -json`.
+type watchEvent struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"` // added, removed or seen
+	Interface string    `json:"interface"`
+	IP        string    `json:"ip,omitempty"` // only for seen
+	MAC       string    `json:"mac"`
+	Vendor    string    `json:"vendor"`
+	Error     string    `json:"error,omitempty"` // why Vendor is empty
+}
+
+// print writes ev as a tab-separated line, or as JSON with asJSON.
+func (ev watchEvent) print(asJSON bool) {
+	if asJSON {
+		_ = json.NewEncoder(os.Stdout).Encode(ev)
+		return
+	}
+	if ev.Event == "seen" {
+		fmt.Printf("%s\tseen\t%s\t%s\t%s\t%s\n", ev.Time.Format(time.RFC3339), ev.Interface, ev.IP, ev.MAC, ev.Vendor)
+		return
+	}
+	vendor := ev.Vendor
+	if ev.Error != "" {
+		vendor = "(" + ev.Error + ")"
+	}
+	fmt.Printf("%s\t%s\t%s\t%s\t%s\n", ev.Time.Format(time.RFC3339), ev.Event, ev.Interface, ev.MAC, vendor)
+}
+
+// runWatch implements `pg-oui watch`: it prints the vendor of every network
+// interface attached after start (netlink on Linux, polling elsewhere), and
+// of removed ones, to spot unexpected NICs and USB dongles.
+func runWatch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	dir := fs.String("dir", "", "data directory containing entries/vendors/vendors.index")
+	initial := fs.Bool("initial", false, "also print interfaces present at start")
+	interval := fs.Duration("interval", 2*time.Second, "poll interval where netlink is unavailable")
+	neighbors := fs.Bool("neighbors", false, "print devices seen on the network (from the neighbor table) instead of local interfaces")
+	iface := fs.String("iface", "", "with -neighbors, only watch this interface")
+	asJSON := fs.Bool("json", false, "print one JSON object per event (see pg-oui schema watch)")
+	debugListen := fs.String("debug-listen", "", "serve pprof and runtime memstats on this address (e.g. localhost:6060)")
+	_ = fs.Parse(args)
+	if *debugListen != "" {
+		startDebugListener(*debugListen)
+	}
+
+	db, err := openDB(*dir)
+	if err != nil {
+		fail("open db", err)
+	}
+	if *neighbors {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err := db.Watch(ctx, *iface, func(ev pg_oui.DeviceEvent) {
+			watchEvent{Time: ev.Time, Event: "seen", Interface: ev.Interface, IP: ev.IP.String(), MAC: ev.MAC.String(), Vendor: ev.Record.Vendor}.print(*asJSON)
+		})
+		if err != nil && ctx.Err() == nil {
+			fail("watch", err)
+		}
+		return
+	}
+
+	// known tracks interfaces by name so link state changes, which Linux
+	// also reports as new links, are not printed as attachments.
+	known := map[string]string{}
+	report := func(ev linkEvent) {
+		if ev.added && known[ev.name] == ev.hw.String() {
+			return
+		}
+		if !ev.added {
+			if _, ok := known[ev.name]; !ok {
+				return
+			}
+			delete(known, ev.name)
+		} else {
+			known[ev.name] = ev.hw.String()
+		}
+		out := watchEvent{Time: time.Now(), Event: "removed", Interface: ev.name, MAC: ev.hw.String()}
+		if ev.added {
+			out.Event = "added"
+		}
+		var err error
+		if out.Vendor, err = db.LookupFromHardwareAddrErr(ev.hw); err != nil {
+			out.Error = err.Error()
+		}
+		out.print(*asJSON)
+	}
+
+	ifs, err := net.Interfaces()
+	if err != nil {
+		fail("list interfaces", err)
+	}
+	for _, ifi := range ifs {
+		if len(ifi.HardwareAddr) == 0 {
+			continue
+		}
+		if *initial {
+			report(linkEvent{added: true, name: ifi.Name, hw: ifi.HardwareAddr})
+		} else {
+			known[ifi.Name] = ifi.HardwareAddr.String()
+		}
+	}
+
+	if err := watchLinks(*interval, report); err != nil {
+		fail("watch", err)
+	}
+}
+
+// pollLinks lists interfaces every interval, reporting each present one as
+// added (runWatch drops repeats) and each vanished one as removed.
+func pollLinks(interval time.Duration, fn func(linkEvent)) error {
+	prev := map[string]net.HardwareAddr{}
+	for {
+		ifs, err := net.Interfaces()
+		if err != nil {
+			return err
+		}
+		cur := map[string]net.HardwareAddr{}
+		for _, ifi := range ifs {
+			if len(ifi.HardwareAddr) > 0 {
+				cur[ifi.Name] = ifi.HardwareAddr
+			}
+		}
+		for name, hw := range prev {
+			if _, ok := cur[name]; !ok {
+				fn(linkEvent{name: name, hw: hw})
+			}
+		}
+		for name, hw := range cur {
+			fn(linkEvent{added: true, name: name, hw: hw})
+		}
+		prev = cur
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// rtmgrpLink is the rtnetlink multicast group for link changes.
+const rtmgrpLink = 0x1
+
+// watchLinks reports link additions and removals from rtnetlink, falling
+// back to polling if the socket cannot be opened.
+func watchLinks(interval time.Duration, fn func(linkEvent)) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err == nil {
+		err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: rtmgrpLink})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "netlink unavailable (%v), polling every %s\n", err, interval)
+		return pollLinks(interval, fn)
+	}
+	defer syscall.Close(fd)
+
+	buf := make([]byte, 1<<16)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return fmt.Errorf("netlink receive: %w", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return fmt.Errorf("netlink parse: %w", err)
+		}
+		for i := range msgs {
+			m := &msgs[i]
+			if m.Header.Type != syscall.RTM_NEWLINK && m.Header.Type != syscall.RTM_DELLINK {
+				continue
+			}
+			attrs, err := syscall.ParseNetlinkRouteAttr(m)
+			if err != nil {
+				continue
+			}
+			ev := linkEvent{added: m.Header.Type == syscall.RTM_NEWLINK}
+			for _, a := range attrs {
+				switch a.Attr.Type {
+				case syscall.IFLA_IFNAME:
+					ev.name = string(bytes.TrimRight(a.Value, "\x00"))
+				case syscall.IFLA_ADDRESS:
+					ev.hw = net.HardwareAddr(bytes.Clone(a.Value))
+				}
+			}
+			if ev.name != "" && len(ev.hw) > 0 {
+				fn(ev)
+			}
+		}
+	}
+}
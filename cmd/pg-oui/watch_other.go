@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "time"
+
+// watchLinks polls for interface changes; netlink is Linux-only.
+func watchLinks(interval time.Duration, fn func(linkEvent)) error {
+	return pollLinks(interval, fn)
+}
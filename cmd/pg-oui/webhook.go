@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// webhook is a sink that POSTs lookup results to a URL as NDJSON batches.
+// Batches are sent when full or every interval, whichever comes first, and
+// retried with exponential backoff on network errors and 5xx responses.
+type webhook struct {
+	url      string
+	batch    int
+	interval time.Duration
+	retries  int
+	client   *http.Client
+	in       chan lookupResult
+	done     chan struct{}
+}
+
+func startWebhook(url string, batch int, interval time.Duration, retries int) *webhook {
+	w := &webhook{
+		url:      url,
+		batch:    batch,
+		interval: interval,
+		retries:  retries,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		in:       make(chan lookupResult, batch),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *webhook) send(r lookupResult) { w.in <- r }
+
+// close flushes pending results and waits for the last batch to be sent.
+func (w *webhook) close() error {
+	close(w.in)
+	<-w.done
+	return nil
+}
+
+func (w *webhook) run() {
+	defer close(w.done)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	n := 0
+	flush := func() {
+		if n == 0 {
+			return
+		}
+		if err := w.post(buf.Bytes()); err != nil {
+			fmt.Fprintf(os.Stderr, "webhook: dropped %d records: %v\n", n, err)
+		}
+		buf.Reset()
+		n = 0
+	}
+	tick := time.NewTicker(w.interval)
+	defer tick.Stop()
+	for {
+		select {
+		case r, ok := <-w.in:
+			if !ok {
+				flush()
+				return
+			}
+			_ = enc.Encode(r)
+			if n++; n >= w.batch {
+				flush()
+			}
+		case <-tick.C:
+			flush()
+		}
+	}
+}
+
+func (w *webhook) post(body []byte) error {
+	backoff := 500 * time.Millisecond
+	var err error
+	for attempt := 0; attempt <= w.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var resp *http.Response
+		resp, err = w.client.Post(w.url, "application/x-ndjson", bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode < 300:
+			return nil
+		case resp.StatusCode < 500:
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		err = fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// chunkSize is the number of input lines handed to a worker at a time.
+const chunkSize = 1024
+
+type chunk struct {
+	lines []string
+	out   []string
+	found []bool
+	done  chan struct{}
+}
+
+// lookupParallel resolves one MAC per input line using the given number of
+// workers and writes one vendor per line to w, in input order. emit is called
+// for every result, also in input order. Unless buffered, a chunk is handed
+// out early when no more input is waiting and output is flushed after each
+// chunk, so a live pipe is answered as it arrives.
+func lookupParallel(db *pg_oui.DB, r io.Reader, w io.Writer, workers int, buffered bool, emit func(input, vendor string, found bool)) error {
+	work := make(chan *chunk, workers)
+	order := make(chan *chunk, workers*2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range work {
+				c.out = make([]string, len(c.lines))
+				c.found = make([]bool, len(c.lines))
+				for i, l := range c.lines {
+					c.out[i], c.found[i] = db.Lookup(l)
+				}
+				close(c.done)
+			}
+		}()
+	}
+
+	// Reader: split input into chunks, queued both for work and for output.
+	var readErr error
+	go func() {
+		defer close(order)
+		defer close(work)
+		br := bufio.NewReaderSize(r, 64<<10)
+		c := &chunk{done: make(chan struct{})}
+		for {
+			line, err := br.ReadString('\n')
+			if len(line) > 0 {
+				c.lines = append(c.lines, line)
+			}
+			// An empty buffer means the next read may block.
+			idle := !buffered && br.Buffered() == 0
+			if len(c.lines) == chunkSize || (err != nil || idle) && len(c.lines) > 0 {
+				order <- c
+				work <- c
+				c = &chunk{done: make(chan struct{})}
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr = err
+				}
+				return
+			}
+		}
+	}()
+
+	// Merger: emit chunks in the order they were read.
+	bw := bufio.NewWriter(w)
+	var writeErr error
+	for c := range order {
+		<-c.done
+		for i, v := range c.out {
+			if writeErr == nil {
+				_, writeErr = bw.WriteString(v + "\n")
+			}
+			emit(c.lines[i], v, c.found[i])
+		}
+		if !buffered && writeErr == nil {
+			writeErr = bw.Flush()
+		}
+	}
+	wg.Wait()
+	if readErr != nil {
+		return readErr
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	return bw.Flush()
+}
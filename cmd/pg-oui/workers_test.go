@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+func TestLookupParallel_LivePipe(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := pg_oui.Build(strings.NewReader(publishCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := pg_oui.Open(pg_oui.WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- lookupParallel(db, inR, outW, 4, false, func(string, string, bool) {}) }()
+
+	// The first line must be answered while the input stays open.
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(outR).ReadString('\n')
+		lines <- line
+	}()
+	if _, err := io.WriteString(inW, "00:11:22:33:44:55\n"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case line := <-lines:
+		if line != "Sony\n" {
+			t.Errorf("got %q, want Sony", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no output before the input was closed")
+	}
+	inW.Close()
+	go io.Copy(io.Discard, outR)
+	if err := <-done; err != nil {
+		t.Errorf("lookupParallel: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// diffReport is the -json output of `update_data diff`.
+type diffReport struct {
+	Added   []pg_oui.VendorChange `json:"added"`
+	Removed []pg_oui.VendorChange `json:"removed"`
+	Renamed []pg_oui.VendorChange `json:"renamed"`
+}
+
+// runDiff implements `update_data diff old-dir new-dir`: it reports the
+// prefixes added, removed or assigned to another vendor between two
+// generated datasets.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print one JSON object with added, removed and renamed lists")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: update_data diff [-json] old-dir new-dir")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var dbs [2]*pg_oui.DB
+	for i, dir := range fs.Args() {
+		db, err := pg_oui.Open(pg_oui.WithDir(dir), pg_oui.WithAutoUpdate(false))
+		if err != nil {
+			log.Fatalf("diff: open %s: %v", dir, err)
+		}
+		dbs[i] = db
+	}
+
+	rep := diffReport{Added: []pg_oui.VendorChange{}, Removed: []pg_oui.VendorChange{}, Renamed: []pg_oui.VendorChange{}}
+	for _, c := range pg_oui.Diff(dbs[0], dbs[1]) {
+		switch c.Kind() {
+		case "added":
+			rep.Added = append(rep.Added, c)
+		case "removed":
+			rep.Removed = append(rep.Removed, c)
+		default:
+			rep.Renamed = append(rep.Renamed, c)
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			log.Fatalf("diff: %v", err)
+		}
+		return
+	}
+	for _, c := range rep.Added {
+		fmt.Printf("+ %s %s\n", c.Prefix, c.New)
+	}
+	for _, c := range rep.Removed {
+		fmt.Printf("- %s %s\n", c.Prefix, c.Old)
+	}
+	for _, c := range rep.Renamed {
+		fmt.Printf("~ %s %s -> %s\n", c.Prefix, c.Old, c.New)
+	}
+	fmt.Printf("%d added, %d removed, %d renamed\n", len(rep.Added), len(rep.Removed), len(rep.Renamed))
+}
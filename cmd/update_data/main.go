@@ -1,10 +1,9 @@
 package main
 
 import (
-	"bufio"
-	"encoding/binary"
-	"encoding/csv"
-	"errors"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"io"
@@ -13,308 +12,176 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"sort"
-	"strconv"
 	"strings"
-)
 
-const (
-	ouiURL = "https://standards-oui.ieee.org/oui/oui.csv"
+	pg_oui "github.com/pre-history/pg-oui"
 )
 
-func download() error {
-
-	log.Printf("downloading %q", ouiURL)
-
-	resp, err := http.Get(ouiURL)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+// quiet suppresses progress output and informational logs.
+var quiet bool
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed: status %d", resp.StatusCode)
+func logf(format string, args ...any) {
+	if !quiet {
+		log.Printf(format, args...)
 	}
+}
 
+// download concatenates the source files at urls into tmp_oui.csv. With
+// conditional set it sends the validators in cache and reports changed=false
+// if every source answered 304 Not Modified; sources that were unchanged
+// while others changed are then fetched in full.
+func download(urls []string, prog *progress, cache pg_oui.SourceCache, conditional bool) (changed bool, err error) {
 	fout, err := os.Create("tmp_oui.csv")
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer fout.Close()
 
-	_, err = io.Copy(fout, resp.Body)
-	if err != nil {
-		return err
+	var unchanged []string
+	for _, u := range urls {
+		ok, err := downloadOne(fout, u, prog, cache, conditional)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", u, err)
+		}
+		if !ok {
+			unchanged = append(unchanged, u)
+		}
 	}
-
-	return nil
-}
-
-type OUI string
-
-type entry struct {
-	OUI      OUI
-	VendorID int
-	Vendor   string
-}
-type templateData struct {
-	Entries []entry
-	Vendors []string
-}
-
-func (o OUI) String() string {
-	return string(o)
-}
-
-func (o OUI) Int() int64 {
-	n, err := strconv.ParseInt(o.String(), 16, 64)
-	if err != nil {
-		panic(err)
+	if len(unchanged) == len(urls) {
+		return false, nil
 	}
-
-	return n
-}
-
-var (
-	llcRegex  = regexp.MustCompile(`(?i),?\s*(llc|ltd|limited|inc|incorporated)\.?$`)
-	coRegex   = regexp.MustCompile(`(?i),?\s*(co|company|corp|corporation)\.?$`)
-	gmbhRegex = regexp.MustCompile(`(?i),?\s*gmbh\.?$`)
-)
-
-func simplifyName(name string) string {
-	b := []byte(name)
-
-	b = llcRegex.ReplaceAll(b, []byte{})
-	b = coRegex.ReplaceAll(b, []byte{})
-	b = gmbhRegex.ReplaceAll(b, []byte{})
-
-	return string(b)
-}
-
-type filter struct {
-	vendorSet   map[string]struct{} // simplified names
-	vendorRegex *regexp.Regexp      // applied to simplified names
-	ouiSet      map[string]struct{} // lower 6-hex
-}
-
-func (f *filter) allowVendor(name string) bool {
-	if f == nil {
-		return true
-	}
-	s := simplifyName(strings.TrimSpace(strings.ReplaceAll(name, `"`, "")))
-	if len(f.vendorSet) > 0 {
-		if _, ok := f.vendorSet[s]; !ok {
-			return false
+	for _, u := range unchanged {
+		if _, err := downloadOne(fout, u, prog, cache, false); err != nil {
+			return false, fmt.Errorf("%s: %w", u, err)
 		}
 	}
-	if f.vendorRegex != nil && !f.vendorRegex.MatchString(s) {
-		return false
-	}
-	return true
+	return true, nil
 }
 
-func (f *filter) allowOUI(o string) bool {
-	if f == nil || len(f.ouiSet) == 0 {
-		return true
+// downloadOne appends url to w and records its validators in cache. It
+// returns false, writing nothing, if a conditional request got 304.
+func downloadOne(w io.Writer, url string, prog *progress, cache pg_oui.SourceCache, conditional bool) (bool, error) {
+	logf("downloading %q", url)
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return true, fetchOne(w, url, prog)
 	}
-	_, ok := f.ouiSet[strings.ToLower(o)]
-	return ok
-}
-
-func newTemplateData(r io.Reader, flt *filter) *templateData {
-
-	var (
-		entries []entry
-		vendors []string
-	)
 
-	ouiMap := make(map[string]string)
-	vendorMap := make(map[string]int)
-
-	c := csv.NewReader(r)
-
-	_, err := c.Read() // skip header
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		panic(err)
+		return false, err
 	}
-
-	for id := 0; ; {
-		record, err := c.Read()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-
-		if err != nil {
-			panic(err)
-		}
-
-		o := strings.ToLower(record[1])
-
-		if flt != nil && !flt.allowOUI(o) {
-			continue
-		}
-
-		v := strings.TrimSpace(record[2])
-		v = strings.ReplaceAll(v, `"`, "")
-		v = simplifyName(v)
-
-		if flt != nil && !flt.allowVendor(v) {
-			continue
-		}
-
-		if prev, ok := ouiMap[o]; ok { // 080030 is a known duplicate
-			log.Printf("Warning %q:%q is already registered to %q", o, v, prev)
-			continue
-		}
-
-		ouiMap[o] = v
-
-		if _, ok := vendorMap[v]; !ok {
-			vendors = append(vendors, v)
-			vendorMap[v] = id
-			id++
-		}
-
-		entries = append(entries, entry{OUI: OUI(o), Vendor: v, VendorID: vendorMap[v]})
-	}
-
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].OUI.Int() < entries[j].OUI.Int()
-	})
-
-	return &templateData{
-		Entries: entries,
-		Vendors: vendors,
+	if conditional {
+		cache.Condition(req)
 	}
-}
-
-func createIndex(dataFile string) error {
-	file, err := os.Open(dataFile)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to open data file: %w", err)
+		return false, err
 	}
-	defer file.Close()
-
-	indexFile, err := os.Create(dataFile + ".index")
-	if err != nil {
-		return fmt.Errorf("failed to create index file: %w", err)
-	}
-	defer indexFile.Close()
-
-	writer := bufio.NewWriter(indexFile)
-	defer writer.Flush()
-
-	scanner := bufio.NewScanner(file)
-	var offset int64 = 0
+	defer resp.Body.Close()
 
-	// Write the offset of the first line (which is always 0)
-	if err := binary.Write(writer, binary.LittleEndian, offset); err != nil {
-		return fmt.Errorf("failed to write offset to index file: %w", err)
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		logf("%q not modified", url)
+		return false, nil
 	}
-
-	for scanner.Scan() {
-		// The new offset is the previous offset plus the length of the line plus the newline character
-		offset += int64(len(scanner.Bytes()) + 1)
-		if err := binary.Write(writer, binary.LittleEndian, offset); err != nil {
-			return fmt.Errorf("failed to write offset to index file: %w", err)
-		}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("download failed: status %d", resp.StatusCode)
 	}
+	cache.Record(url, resp)
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error while scanning data file: %w", err)
+	prog.begin("download", resp.ContentLength)
+	if _, err := io.Copy(w, &countingReader{r: resp.Body, p: prog}); err != nil {
+		return false, err
 	}
+	prog.finish()
 
-	fmt.Println("Index file created successfully.")
-	return nil
+	// Registries may lack a trailing newline; keep the next header on its own row.
+	_, err = io.WriteString(w, "\n")
+	return true, err
 }
 
-func getLine(dataFile string, lineNumber int) (string, error) {
-	indexFileName := dataFile + ".index"
-	indexFile, err := os.Open(indexFileName)
+// fetchOne appends url, fetched by the pg_oui.Fetcher of its scheme (a
+// file or e.g. s3 URL), to w.
+func fetchOne(w io.Writer, url string, prog *progress) error {
+	rc, err := pg_oui.FetchURL(context.Background(), nil, url)
 	if err != nil {
-		return "", fmt.Errorf("failed to open index file. Please create it first using 'create-index': %w", err)
-	}
-	defer indexFile.Close()
-
-	// Each offset is stored as a 64-bit integer (8 bytes)
-	offsetPosition := int64(lineNumber-1) * 8
-	if _, err := indexFile.Seek(offsetPosition, io.SeekStart); err != nil {
-		return "", fmt.Errorf("failed to seek in index file: %w", err)
-	}
-
-	var offset int64
-	if err := binary.Read(indexFile, binary.LittleEndian, &offset); err != nil {
-		if err == io.EOF {
-			return "", fmt.Errorf("line number %d is out of range", lineNumber)
-		}
-		return "", fmt.Errorf("failed to read offset from index file: %w", err)
-	}
-
-	dataFileHandle, err := os.Open(dataFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to open data file: %w", err)
-	}
-	defer dataFileHandle.Close()
-
-	if _, err := dataFileHandle.Seek(offset, io.SeekStart); err != nil {
-		return "", fmt.Errorf("failed to seek in data file: %w", err)
+		return err
 	}
-
-	reader := bufio.NewReader(dataFileHandle)
-	line, err := reader.ReadString('\n')
-	if err != nil && err != io.EOF {
-		return "", fmt.Errorf("failed to read line from data file: %w", err)
+	defer rc.Close()
+	prog.begin("download", -1)
+	if _, err := io.Copy(w, &countingReader{r: rc, p: prog}); err != nil {
+		return err
 	}
-
-	return line, nil
+	prog.finish()
+	_, err = io.WriteString(w, "\n")
+	return err
 }
 
-func updateData(outdir string, flt *filter) {
+func updateData(outdir string, opts *pg_oui.BuildOptions, source string, prog *progress, cache pg_oui.SourceCache) {
 	file, err := os.Open("tmp_oui.csv")
 	if err != nil {
 		return
 	}
 	defer file.Close()
 
-	data := newTemplateData(file, flt)
+	var size int64
+	if st, err := file.Stat(); err == nil {
+		size = st.Size()
+	}
+	prog.begin("build", size)
+	opts.Progress = prog.setRows
 
 	if outdir == "" {
 		outdir = "."
 	}
-	if err := os.MkdirAll(outdir, 0o755); err != nil {
-		log.Printf("failed to create outdir %q: %v", outdir, err)
-		return
+	pre := countLines(filepath.Join(outdir, "entries"))
+	if pre == 0 {
+		pre = countLines(filepath.Join(outdir, pg_oui.CurrentLink, "entries"))
 	}
 
-	fileEntries, err := os.Create(filepath.Join(outdir, "entries"))
+	h := sha256.New()
+	res, err := pg_oui.Build(io.TeeReader(&countingReader{r: file, p: prog}, h), outdir, opts)
 	if err != nil {
+		log.Printf("build dataset: %v", err)
 		return
 	}
-	defer fileEntries.Close()
-
-	w := bufio.NewWriter(fileEntries)
-	for _, entryRow := range data.Entries {
-		fmt.Fprintln(w, fmt.Sprintf("%s,%d", entryRow.OUI.String(), entryRow.VendorID))
+	prog.finish()
+	if len(res.Issues) > 0 {
+		logf("quality report: %d issues", len(res.Issues))
+		for _, is := range res.Issues {
+			logf("  %s", is)
+		}
 	}
-	_ = w.Flush()
+	logf("wrote %d entries, %d vendors to %q", res.Entries, res.Vendors, outdir)
 
-	fileVendors, err := os.Create(filepath.Join(outdir, "vendors"))
-	if err != nil {
-		return
+	rec := pg_oui.UpdateRecord{
+		Trigger:     "update_data",
+		Source:      source,
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		PreEntries:  pre,
+		PostEntries: res.Entries,
 	}
-	defer fileVendors.Close()
-
-	w = bufio.NewWriter(fileVendors)
-	for _, vendorRow := range data.Vendors {
-		fmt.Fprintln(w, vendorRow)
+	if err := pg_oui.AppendUpdateRecord(outdir, rec); err != nil {
+		log.Printf("failed to record update: %v", err)
+	}
+	if cache != nil {
+		if err := cache.Write(outdir); err != nil {
+			log.Printf("failed to record source validators: %v", err)
+		}
 	}
-	_ = w.Flush()
-
-	_ = createIndex(fileVendors.Name())
 
 	os.Remove("tmp_oui.csv")
 
 }
+
+// countLines returns the number of lines in path, or 0 if it cannot be read.
+func countLines(path string) int {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(b), "\n")
+}
+
 func readLines(path string) ([]string, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
@@ -332,88 +199,184 @@ func readLines(path string) ([]string, error) {
 	return out, nil
 }
 
-func parseFilter(includeVendors, includeVendorsFile, includeOUIs, includeOUIsFile, vendorRegex string) (*filter, error) {
-	f := &filter{vendorSet: map[string]struct{}{}, ouiSet: map[string]struct{}{}}
-	// vendor set (comma)
-	if includeVendors != "" {
-		for _, v := range strings.Split(includeVendors, ",") {
-			v = simplifyName(strings.TrimSpace(v))
-			if v != "" {
-				f.vendorSet[v] = struct{}{}
+// listFlag returns the comma-separated values of list plus the lines of file.
+func listFlag(list, file string) ([]string, error) {
+	var out []string
+	if list != "" {
+		for _, v := range strings.Split(list, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
 			}
 		}
 	}
-	// vendor set (file)
-	if includeVendorsFile != "" {
-		lines, err := readLines(includeVendorsFile)
+	if file != "" {
+		lines, err := readLines(file)
 		if err != nil {
-			return nil, fmt.Errorf("read vendors file: %w", err)
-		}
-		for _, v := range lines {
-			v = simplifyName(strings.TrimSpace(v))
-			if v != "" {
-				f.vendorSet[v] = struct{}{}
-			}
+			return nil, err
 		}
+		out = append(out, lines...)
 	}
-	// vendor regex
-	if vendorRegex != "" {
-		rx, err := regexp.Compile(vendorRegex)
-		if err != nil {
-			return nil, fmt.Errorf("compile vendor-regex: %w", err)
-		}
-		f.vendorRegex = rx
-	}
-	// OUI set (comma)
-	if includeOUIs != "" {
-		for _, o := range strings.Split(includeOUIs, ",") {
-			o = strings.ToLower(strings.TrimSpace(o))
-			o = strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(o, ":", ""), "-", ""), ".", "")
-			if len(o) >= 6 {
-				f.ouiSet[o[:6]] = struct{}{}
-			}
-		}
+	return out, nil
+}
+
+func compileRegex(name, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
 	}
-	// OUI set (file)
-	if includeOUIsFile != "" {
-		lines, err := readLines(includeOUIsFile)
-		if err != nil {
-			return nil, fmt.Errorf("read ouis file: %w", err)
-		}
-		for _, o := range lines {
-			o = strings.ToLower(strings.TrimSpace(o))
-			o = strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(o, ":", ""), "-", ""), ".", "")
-			if len(o) >= 6 {
-				f.ouiSet[o[:6]] = struct{}{}
-			}
-		}
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("compile %s: %w", name, err)
 	}
-	// If both vendor and OUI filters empty and no regex, return nil to avoid filter cost
-	if len(f.vendorSet) == 0 && len(f.ouiSet) == 0 && f.vendorRegex == nil {
-		return nil, nil
+	return rx, nil
+}
+
+func parseDuplicates(s string) (pg_oui.DuplicatePolicy, error) {
+	switch s {
+	case "", "first":
+		return pg_oui.DuplicateFirst, nil
+	case "last":
+		return pg_oui.DuplicateLast, nil
+	case "error":
+		return pg_oui.DuplicateError, nil
 	}
-	return f, nil
+	return 0, fmt.Errorf("unknown -dedup policy %q (want first, last or error)", s)
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		runDiff(os.Args[2:])
+		return
+	}
 	outdir := flag.String("outdir", ".", "output directory for entries/vendors and index")
 	incV := flag.String("include-vendors", "", "comma-separated list of vendor names to include (simplified)")
 	incVFile := flag.String("include-vendors-file", "", "file with vendor names to include (one per line)")
 	incO := flag.String("include-ouis", "", "comma-separated list of OUIs to include (e.g. 0CB4A4, 00:11:22)")
 	incOFile := flag.String("include-ouis-file", "", "file with OUIs to include (one per line)")
 	vRegex := flag.String("vendor-regex", "", "regex applied to simplified vendor names to include")
+	excV := flag.String("exclude-vendors", "", "comma-separated list of vendor names to exclude (simplified)")
+	excVFile := flag.String("exclude-vendors-file", "", "file with vendor names to exclude (one per line)")
+	excO := flag.String("exclude-ouis", "", "comma-separated list of OUIs to exclude")
+	excOFile := flag.String("exclude-ouis-file", "", "file with OUIs to exclude (one per line)")
+	excRegex := flag.String("exclude-regex", "", "regex applied to simplified vendor names to exclude")
+	aliases := flag.String("aliases", "", "file of \"variant,canonical\" vendor name lines; variants are renamed to the canonical name")
+	keepRaw := flag.Bool("keep-raw-names", false, "keep vendor names as registered (no LLC/Ltd/Inc removal)")
+	maxVendorLen := flag.Int("max-vendor-len", 0, "truncate vendor names longer than this many characters (0 = unlimited)")
+	sanitize := flag.Bool("sanitize-vendors", false, "strip control characters and trademark symbols from vendor names")
+	dedup := flag.String("dedup", "first", "which row wins for OUIs listed more than once: first, last or error")
+	entriesFormat := flag.String("entries-format", pg_oui.EntriesV1, "entries file format: v1 (CSV) or v2 (tab-separated, with header)")
+	binaryOut := flag.Bool("binary", false, "write a single oui.bin dataset instead of entries, vendors and vendors.index")
+	progressMode := flag.String("progress", "text", "progress output on stderr: text or json")
+	flag.BoolVar(&quiet, "quiet", false, "suppress progress output and informational logs")
+	source := flag.String("source", pg_oui.SourceIEEE, "input data: ieee (registry CSVs), wireshark (manuf file) or nmap (nmap-mac-prefixes)")
+	registries := flag.String("registries", "ma-l,ma-m,ma-s", "comma-separated IEEE registries to include: ma-l, ma-m, ma-s, cid")
+	srcURL := flag.String("url", "", "comma-separated URLs to download instead of the upstream files of -source, e.g. an internal mirror of the IEEE CSV")
 	skipDownload := flag.Bool("skip-download", false, "reuse existing tmp_oui.csv if present")
+	lock := flag.Bool("lock", false, "hold "+pg_oui.LockName+" in -outdir while updating; fail if another update holds it")
+	force := flag.Bool("force", false, "download and rebuild even if the upstream files are unchanged")
 	flag.Parse()
 
-	flt, err := parseFilter(*incV, *incVFile, *incO, *incOFile, *vRegex)
+	opts := &pg_oui.BuildOptions{
+		KeepRawNames:    *keepRaw,
+		SanitizeVendors: *sanitize,
+		MaxVendorLen:    *maxVendorLen,
+		Format:          *entriesFormat,
+		Binary:          *binaryOut,
+		Source:          *source,
+	}
+	opts.Registries, _ = listFlag(*registries, "")
+	urls, err := pg_oui.SourceURLs(*source, opts.Registries)
 	if err != nil {
+		log.Fatalf("source: %v", err)
+	}
+	if *srcURL != "" {
+		urls, _ = listFlag(*srcURL, "")
+	}
+	if opts.IncludeVendors, err = listFlag(*incV, *incVFile); err != nil {
+		log.Fatalf("read vendors file: %v", err)
+	}
+	if opts.IncludeOUIs, err = listFlag(*incO, *incOFile); err != nil {
+		log.Fatalf("read ouis file: %v", err)
+	}
+	if opts.ExcludeVendors, err = listFlag(*excV, *excVFile); err != nil {
+		log.Fatalf("read exclude vendors file: %v", err)
+	}
+	if opts.ExcludeOUIs, err = listFlag(*excO, *excOFile); err != nil {
+		log.Fatalf("read exclude ouis file: %v", err)
+	}
+	if *aliases != "" {
+		f, err := os.Open(*aliases)
+		if err != nil {
+			log.Fatalf("read aliases file: %v", err)
+		}
+		opts.Aliases, err = pg_oui.ReadAliases(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("read aliases file %s: %v", *aliases, err)
+		}
+	}
+	if opts.VendorRegex, err = compileRegex("vendor-regex", *vRegex); err != nil {
+		log.Fatalf("filter error: %v", err)
+	}
+	if opts.ExcludeRegex, err = compileRegex("exclude-regex", *excRegex); err != nil {
+		log.Fatalf("filter error: %v", err)
+	}
+	if opts.Duplicates, err = parseDuplicates(*dedup); err != nil {
 		log.Fatalf("filter error: %v", err)
 	}
 
-	if !*skipDownload {
-		if err := download(); err != nil {
+	prog := &progress{mode: *progressMode}
+	switch {
+	case quiet:
+		prog.mode = ""
+	case *progressMode != "text" && *progressMode != "json":
+		log.Fatalf("unknown -progress mode %q (want text or json)", *progressMode)
+	}
+
+	unlock := func() {}
+	if *lock {
+		if unlock, err = pg_oui.LockDir(*outdir); err != nil {
+			log.Fatalf("update: %v", err)
+		}
+	}
+	defer unlock()
+
+	from := strings.Join(urls, " ")
+	var cache pg_oui.SourceCache
+	if *skipDownload {
+		from = "tmp_oui.csv"
+	} else {
+		if cache, err = pg_oui.ReadSourceCache(*outdir); err != nil {
+			logf("ignoring source validators: %v", err)
+			cache = pg_oui.SourceCache{}
+		}
+		changed, err := download(urls, prog, cache, !*force && hasDataset(*outdir))
+		if err != nil {
+			unlock()
 			log.Fatalf("download: %v", err)
 		}
+		if !changed {
+			os.Remove("tmp_oui.csv")
+			logf("upstream unchanged since the last update; keeping the dataset in %q", *outdir)
+			return
+		}
+	}
+	opts.Origin = from
+	opts.Attribution = pg_oui.SourceAttribution(*source, opts.Registries, urls)
+	if st, err := os.Stat("tmp_oui.csv"); err == nil && *skipDownload {
+		opts.Attribution.RetrievedAt = st.ModTime()
+	}
+	updateData(*outdir, opts, from, prog, cache)
+}
+
+// hasDataset reports whether dir, or the dataset dir/current points at,
+// already holds a built dataset.
+func hasDataset(dir string) bool {
+	for _, d := range []string{dir, filepath.Join(dir, pg_oui.CurrentLink)} {
+		for _, n := range []string{pg_oui.BinaryName, "entries"} {
+			if _, err := os.Stat(filepath.Join(d, n)); err == nil {
+				return true
+			}
+		}
 	}
-	updateData(*outdir, flt)
+	return false
 }
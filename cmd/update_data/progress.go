@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// progress reports the download and build phases on stderr, either as a
+// single updating text line or as one JSON object per update.
+type progress struct {
+	mode  string // "text", "json", or "" for no output
+	phase string
+	total int64 // bytes expected in this phase, or <= 0 if unknown
+	done  int64
+	rows  int
+	start time.Time
+	last  time.Time
+}
+
+// progressEvent is the JSON form of a progress update.
+type progressEvent struct {
+	Phase      string  `json:"phase"`
+	Bytes      int64   `json:"bytes"`
+	TotalBytes int64   `json:"total_bytes,omitempty"`
+	Percent    float64 `json:"percent,omitempty"`
+	Rows       int     `json:"rows,omitempty"`
+	ETASeconds float64 `json:"eta_seconds,omitempty"`
+	Done       bool    `json:"done,omitempty"`
+}
+
+// begin starts a new phase expecting total bytes.
+func (p *progress) begin(phase string, total int64) {
+	p.phase, p.total, p.done, p.rows = phase, total, 0, 0
+	p.start, p.last = time.Now(), time.Time{}
+}
+
+func (p *progress) addBytes(n int64) { p.done += n; p.report(false) }
+func (p *progress) setRows(n int)    { p.rows = n; p.report(false) }
+func (p *progress) finish()          { p.report(true) }
+
+// report prints the current state, at most every 200ms unless final.
+func (p *progress) report(final bool) {
+	if p.mode == "" || (!final && time.Since(p.last) < 200*time.Millisecond) {
+		return
+	}
+	p.last = time.Now()
+	ev := progressEvent{Phase: p.phase, Bytes: p.done, Rows: p.rows, Done: final}
+	if p.total > 0 {
+		ev.TotalBytes = p.total
+		ev.Percent = 100 * float64(p.done) / float64(p.total)
+		if p.done > 0 && !final {
+			elapsed := time.Since(p.start).Seconds()
+			ev.ETASeconds = elapsed * float64(p.total-p.done) / float64(p.done)
+		}
+	}
+	if p.mode == "json" {
+		b, _ := json.Marshal(ev)
+		fmt.Fprintf(os.Stderr, "%s\n", b)
+		return
+	}
+	line := fmt.Sprintf("%-8s %s", p.phase, mb(p.done))
+	if p.total > 0 {
+		line = fmt.Sprintf("%-8s %5.1f%% %s/%s", p.phase, ev.Percent, mb(p.done), mb(p.total))
+	}
+	if p.rows > 0 {
+		line += fmt.Sprintf(" %d rows", p.rows)
+	}
+	if ev.ETASeconds > 0 {
+		line += fmt.Sprintf(" ETA %s", time.Duration(ev.ETASeconds*float64(time.Second)).Round(time.Second))
+	}
+	end := ""
+	if final {
+		end = "\n"
+	}
+	fmt.Fprintf(os.Stderr, "\r%-60s%s", line, end)
+}
+
+func mb(n int64) string { return fmt.Sprintf("%.1fMB", float64(n)/(1<<20)) }
+
+// countingReader reports every read to a progress.
+type countingReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.p.addBytes(int64(n))
+	return n, err
+}
@@ -2,39 +2,118 @@ package pg_oui
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
-	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"iter"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // DB is an in-memory OUI database backed by files loaded from an fs.FS.
-// It is safe for concurrent Lookups after Open completes.
+// It is safe for concurrent Lookups after Open completes, also while Reload
+// swaps in new data.
 type DB struct {
-	entries map[string]int // OUI (lower, 6 chars) -> vendorID (1-based)
-	vendors []byte         // full vendors file contents
-	offsets []int64        // little-endian 64-bit offsets, length = lines+1
+	cur      atomic.Pointer[dataset]
+	open     func(context.Context) (*dataset, error) // loads the dataset the way Open did
+	onChange func([]VendorChange)
+	aliases  map[string]string // see WithAliases, keyed by aliasKey
+	weights  map[string]int    // see WithPopularity, keyed by aliasKey
+	byCount  bool              // see WithRankByPrefixes
+	mu       sync.Mutex        // serializes Reload
+	stop     chan struct{}     // closed by Close to end WithWatch polling
+	once     sync.Once
 }
 
+// dataset is one immutable load of the data files.
+type dataset struct {
+	entries    prefixTable         // prefix (lower hex, 6/7/9 chars for MA-L/M/S) -> vendorID
+	vendors    string              // full vendors file contents
+	offsets    []int64             // little-endian 64-bit offsets, length = lines+1
+	strict     bool                // reject malformed input instead of normalizing it
+	truncate   bool                // see WithTruncate
+	noSpecial  bool                // ignore specialRanges, see WithSpecialRanges
+	overrides  map[uint64]string   // see WithOverrides, keyed by overrideKey
+	precedence []Layer             // see WithPrecedence; nil for the default
+	dups       []string            // OUIs that appeared more than once in the entries file
+	source     string              // where the dataset was loaded from, see Metadata
+	records    map[string][]string // v2 extra fields per prefix, see LookupRecord
+	fsys       fs.FS               // where the files were read from, for WithWatch
+	stamp      string              // fileStamp taken before reading, for WithWatch
+	info       DatasetInfo         // DatasetInfoName contents, if present
+}
+
+var (
+	// ErrInvalidMAC is returned for input that is not a well-formed MAC or OUI.
+	ErrInvalidMAC = errors.New("invalid MAC address")
+	// ErrNotFound is returned when the OUI is not in the dataset.
+	ErrNotFound = errors.New("OUI not found")
+	// ErrLocallyAdministered is returned by LookupStrict for locally
+	// administered addresses, such as randomized Wi-Fi MACs, which no
+	// registry assigns.
+	ErrLocallyAdministered = errors.New("locally administered address")
+	// ErrDatasetNotFound is returned by Open when no dataset files exist.
+	ErrDatasetNotFound = errors.New("dataset not found")
+	// ErrCorruptDataset is returned by Open for data files that cannot be
+	// parsed or fail validation.
+	ErrCorruptDataset = errors.New("corrupt dataset")
+	// ErrIndexCorrupt is returned by Open for a vendors index that cannot be
+	// parsed or does not belong to the vendors file. It matches
+	// ErrCorruptDataset too.
+	ErrIndexCorrupt = fmt.Errorf("%w: bad vendors index", ErrCorruptDataset)
+	// ErrEmptyIndex is returned by Open for a vendors index without
+	// offsets. It matches ErrIndexCorrupt and ErrCorruptDataset too.
+	ErrEmptyIndex = fmt.Errorf("%w: index is empty", ErrIndexCorrupt)
+	// ErrDownloadFailed is returned by Open when the auto-update could not
+	// fetch the registry data.
+	ErrDownloadFailed = errors.New("download failed")
+)
+
 // Option configures Open.
 type Option func(*openCfg)
 
 type openCfg struct {
-	fsys        fs.FS
-	dir         string
-	entriesName string
-	vendorsName string
-	indexName   string
-	autoUpdate  bool
-	cacheDir    string
-	httpClient  any
-	filter      *Filter
+	fsys           fs.FS
+	dir            string
+	entriesName    string
+	vendorsName    string
+	indexName      string
+	autoUpdate     bool
+	cacheDir       string
+	httpClient     *http.Client
+	downloadURL    string
+	sourceURL      string
+	build          *BuildOptions
+	registries     []string
+	strict         bool
+	truncate       bool
+	noSpecial      bool
+	jitter         time.Duration
+	schedulerURL   string
+	minInterval    time.Duration
+	validation     ValidationLevel
+	dupPolicy      DuplicatePolicy
+	fallback       []Source
+	watch          time.Duration
+	onChange       func([]VendorChange)
+	onReloadError  func(error)
+	overrides      map[string]string
+	precedence     []Layer
+	aliases        map[string]string
+	popularity     map[string]int
+	rankByPrefixes bool
 }
 
 // WithFS sets the filesystem to load data files from.
@@ -74,16 +153,89 @@ func WithAutoUpdate(v bool) Option { return func(c *openCfg) { c.autoUpdate = v
 // If not set, it uses $PG_OUI_DATA_DIR or the user's cache dir (pg-oui) fallback.
 func WithCacheDir(dir string) Option { return func(c *openCfg) { c.cacheDir = dir } }
 
-// WithHTTPClient overrides the HTTP client used for downloading when built with the
-// 'oui_runtime_update' build tag. In default builds, it has no effect.
-func WithHTTPClient(cl any) Option { return func(c *openCfg) { c.httpClient = cl } }
+// WithHTTPClient sets the HTTP client used for auto-update downloads and by
+// HTTPSource fallbacks created with a nil client, e.g. one going through a
+// proxy. The default has a 30s timeout.
+func WithHTTPClient(cl *http.Client) Option { return func(c *openCfg) { c.httpClient = cl } }
+
+// WithDownloadURL makes auto-update fetch the source files from a mirror at
+// baseURL instead of upstream, under their upstream file names (oui.csv,
+// mam.csv, ...). It has no effect in default builds.
+func WithDownloadURL(baseURL string) Option { return func(c *openCfg) { c.downloadURL = baseURL } }
+
+// WithSourceURL makes auto-update download the source data from url alone,
+// e.g. a copy of the IEEE CSV on an internal artifact mirror, instead of
+// the upstream files. It wins over WithDownloadURL and has no effect in
+// default builds.
+func WithSourceURL(url string) Option { return func(c *openCfg) { c.sourceURL = url } }
 
 // WithFilter applies a filter when generating the dataset during auto-update.
 // It has no effect if data is already present.
-func WithFilter(f *Filter) Option { return func(c *openCfg) { c.filter = f } }
+func WithFilter(f *Filter) Option { return func(c *openCfg) { c.build = f.buildOptions() } }
+
+// WithBuildOptions sets the options used when generating the dataset during
+// auto-update. It has no effect if data is already present.
+func WithBuildOptions(o *BuildOptions) Option { return func(c *openCfg) { c.build = o } }
+
+// WithRegistries selects the IEEE registries downloaded during auto-update
+// (see RegistryURLs). It has no effect if data is already present.
+func WithRegistries(names ...string) Option { return func(c *openCfg) { c.registries = names } }
+
+// WithUpdateJitter delays an auto-update download by a random duration in
+// [0, d), so a fleet started at the same time does not fetch all at once.
+// It has no effect in default builds.
+func WithUpdateJitter(d time.Duration) Option { return func(c *openCfg) { c.jitter = d } }
+
+// WithUpdateScheduler makes auto-update ask the endpoint at url, the
+// /v1/update-slot of a `pg-oui serve -update-spacing` instance, how long to
+// wait before downloading, so a fleet fetches one after another in slots
+// the server hands out. If the server cannot be reached, WithUpdateJitter
+// applies. It has no effect in default builds.
+func WithUpdateScheduler(url string) Option { return func(c *openCfg) { c.schedulerURL = url } }
+
+// WithMinUpdateInterval skips auto-update downloads when the last attempt
+// recorded in the data dir's .last-fetch marker is more recent than d.
+// It has no effect in default builds.
+func WithMinUpdateInterval(d time.Duration) Option { return func(c *openCfg) { c.minInterval = d } }
+
+// WithStrictInput makes lookups reject input that is not exactly 6, 12 or 16
+// hex digits (after stripping separators) instead of truncating it.
+func WithStrictInput(v bool) Option { return func(c *openCfg) { c.strict = v } }
+
+// WithTruncate makes lookups ignore what follows the hex digits of the
+// input, or its hex digits past the 16th, as Normalize does, so that
+// redacted MACs like "b8:27:eb:xx:xx:xx" resolve. Without it such input is
+// ErrInvalidMAC, so malformed data upstream does not go unnoticed.
+// LookupRecord reports truncated input in Record.Truncated.
+func WithTruncate(v bool) Option { return func(c *openCfg) { c.truncate = v } }
+
+// WithSpecialRanges controls the built-in table of well-known ranges (VRRP
+// and HSRP virtual routers, broadcast and multicast, hypervisor and Docker
+// NICs) that lookups consult alongside the dataset; a range wins when it is
+// longer than the registered prefix. It is on by default.
+func WithSpecialRanges(v bool) Option { return func(c *openCfg) { c.noSpecial = !v } }
+
+// DuplicatePolicy decides which entry wins when the entries file lists the
+// same OUI more than once.
+type DuplicatePolicy int
+
+const (
+	DuplicateDefault DuplicatePolicy = iota // Open keeps the last occurrence, Build the first
+	DuplicateFirst                          // keep the first occurrence
+	DuplicateLast                           // keep the last occurrence
+	DuplicateError                          // fail
+)
+
+// WithDuplicatePolicy sets how Open handles duplicate OUIs in the entries
+// file. Duplicates are also reported by Validate at ValidateThorough.
+func WithDuplicatePolicy(p DuplicatePolicy) Option { return func(c *openCfg) { c.dupPolicy = p } }
 
 // Open loads the OUI dataset from the provided fs and returns a DB.
-func Open(opts ...Option) (*DB, error) {
+func Open(opts ...Option) (*DB, error) { return OpenContext(context.Background(), opts...) }
+
+// OpenContext is like Open but stops waiting for the auto-update download
+// and jitter or scheduler delay once ctx is done, returning ctx's error.
+func OpenContext(ctx context.Context, opts ...Option) (*DB, error) {
 	cfg := openCfg{
 		fsys:        nil,
 		entriesName: defaultEntries,
@@ -94,50 +246,140 @@ func Open(opts ...Option) (*DB, error) {
 	for _, o := range opts {
 		o(&cfg)
 	}
+	if err := checkPrecedence(cfg.precedence); err != nil {
+		return nil, err
+	}
+	db := &DB{open: func(ctx context.Context) (*dataset, error) { return openDataset(ctx, &cfg) }, onChange: cfg.onChange, aliases: newAliasTable(cfg.aliases), weights: newPopularityTable(cfg.popularity), byCount: cfg.rankByPrefixes, stop: make(chan struct{})}
+	if err := db.reload(ctx); err != nil {
+		return nil, err
+	}
+	if cfg.watch > 0 {
+		go db.watch(&cfg, cfg.watch)
+	}
+	return db, nil
+}
+
+// openDataset resolves the data location for cfg and loads it.
+func openDataset(ctx context.Context, cfg *openCfg) (*dataset, error) {
+	if len(cfg.fallback) > 0 {
+		return openFallback(ctx, cfg)
+	}
+	source := "dir"
+	if cfg.fsys != nil {
+		source = "fs"
+	}
 	// Resolve filesystem or generate into cache dir if missing
-	fsys, err := resolveOrBuild(&cfg)
+	fsys, err := resolveOrBuild(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return load(cfg, fsys, source)
+}
+
+// load reads and validates the dataset in fsys, either BinaryName or the
+// three-file layout. source is recorded in the DB's Metadata. On a local fs
+// a read that overlaps Build replacing the files could mix two builds, so
+// load reads again unless no replacement was under way and the files stayed
+// the same throughout, and fails if they are still changing after
+// maxLoadTries reads.
+func load(cfg *openCfg, fsys fs.FS, source string) (*dataset, error) {
+	_, local := fsys.(fs.StatFS)
+	for try := 1; ; try++ {
+		stable := !local || !replacing(fsys)
+		var stamp string
+		if local {
+			stamp = fileStamp(fsys, cfg)
+		}
+		d, err := readDataset(cfg, fsys, source, stamp)
+		if !local || stable && fileStamp(fsys, cfg) == stamp && !replacing(fsys) {
+			return d, err
+		}
+		if try == maxLoadTries {
+			return nil, fmt.Errorf("load dataset: files still being replaced after %d reads", maxLoadTries)
+		}
+		time.Sleep(time.Duration(try) * 10 * time.Millisecond)
+	}
+}
+
+// maxLoadTries bounds how often load reads a dataset that keeps changing.
+const maxLoadTries = 5
+
+// replacing reports whether replaceDataset is renaming files in fsys.
+func replacing(fsys fs.FS) bool {
+	_, err := fs.Stat(fsys, replacingName)
+	return err == nil
+}
+
+// readDataset is one attempt of load; stamp is the fileStamp taken before.
+func readDataset(cfg *openCfg, fsys fs.FS, source, stamp string) (*dataset, error) {
+	var info DatasetInfo
+	if b, err := fs.ReadFile(fsys, DatasetInfoName); err == nil {
+		if err := json.Unmarshal(b, &info); err != nil {
+			return nil, fmt.Errorf("parse %s: %w: %w", DatasetInfoName, ErrCorruptDataset, err)
+		}
+	}
+	overrides, err := readOverrides(cfg, fsys)
 	if err != nil {
 		return nil, err
 	}
-	cfg.fsys = fsys
+	if b, err := fs.ReadFile(fsys, BinaryName); err == nil {
+		entries, vendors, offsets, err := readBinary(b)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w: %w", BinaryName, ErrCorruptDataset, err)
+		}
+		d := &dataset{entries: newPrefixTable(entries), vendors: string(vendors), offsets: offsets, strict: cfg.strict, truncate: cfg.truncate, noSpecial: cfg.noSpecial, overrides: overrides, precedence: cfg.precedence, source: source, fsys: fsys, stamp: stamp, info: info}
+		if err := d.validate(cfg.validation); err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+
 	// Load entries
-	entriesFile, err := cfg.fsys.Open(cfg.entriesName)
+	entriesFile, err := fsys.Open(cfg.entriesName)
 	if err != nil {
 		return nil, fmt.Errorf("open entries: %w", err)
 	}
 	defer entriesFile.Close()
-	reader := csv.NewReader(entriesFile)
 	entries := make(map[string]int, 4096)
-	for {
-		rec, err := reader.Read()
-		if errors.Is(err, io.EOF) {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("read entries: %w", err)
-		}
-		if len(rec) < 2 {
-			continue
+	var dups []string
+	var records map[string][]string
+	err = readEntries(entriesFile, func(oui string, id int, extra []string) {
+		if _, seen := entries[oui]; seen {
+			dups = append(dups, oui)
+			if cfg.dupPolicy == DuplicateFirst {
+				return
+			}
 		}
-		// vendorID must be numeric; ignore malformed
-		id, err := atoi(rec[1])
-		if err != nil {
-			continue
+		entries[oui] = id
+		if len(extra) > 0 {
+			if records == nil {
+				records = make(map[string][]string)
+			}
+			records[oui] = extra
 		}
-		entries[rec[0]] = id
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read entries: %w: %w", ErrCorruptDataset, err)
+	}
+	if len(dups) > 0 && cfg.dupPolicy == DuplicateError {
+		return nil, fmt.Errorf("read entries: %w: %d duplicate OUIs (first %q)", ErrCorruptDataset, len(dups), dups[0])
 	}
 
 	// Load vendors file into memory
-	vendorsBytes, err := fs.ReadFile(cfg.fsys, cfg.vendorsName)
+	vendorsBytes, err := fs.ReadFile(fsys, cfg.vendorsName)
 	if err != nil {
 		return nil, fmt.Errorf("read vendors: %w", err)
 	}
 
 	// Load index (sequence of little-endian int64 offsets)
-	indexBytes, err := fs.ReadFile(cfg.fsys, cfg.indexName)
+	indexBytes, err := fs.ReadFile(fsys, cfg.indexName)
 	if err != nil {
 		return nil, fmt.Errorf("read index: %w", err)
 	}
+	indexBytes, err = splitIndex(indexBytes, vendorsBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse index: %w: %w", ErrIndexCorrupt, err)
+	}
 	r := bytes.NewReader(indexBytes)
 	var offsets []int64
 	for {
@@ -146,88 +388,393 @@ func Open(opts ...Option) (*DB, error) {
 			if errors.Is(err, io.EOF) {
 				break
 			}
-			return nil, fmt.Errorf("parse index: %w", err)
+			return nil, fmt.Errorf("parse index: %w: %w", ErrIndexCorrupt, err)
 		}
 		offsets = append(offsets, off)
 	}
 	if len(offsets) == 0 {
-		return nil, fmt.Errorf("index is empty")
+		return nil, ErrEmptyIndex
 	}
 
-	return &DB{entries: entries, vendors: vendorsBytes, offsets: offsets}, nil
+	d := &dataset{entries: newPrefixTable(entries), vendors: string(vendorsBytes), offsets: offsets, strict: cfg.strict, truncate: cfg.truncate, noSpecial: cfg.noSpecial, overrides: overrides, precedence: cfg.precedence, dups: dups, source: source, records: records, fsys: fsys, stamp: stamp, info: info}
+	if err := d.validate(cfg.validation); err != nil {
+		return nil, err
+	}
+	return d, nil
 }
 
 // Lookup returns the vendor name for the given MAC (or OUI) string.
-// It returns ok=false when the OUI is unknown.
+// It returns ok=false when the OUI is unknown or the input is malformed.
 func (db *DB) Lookup(s string) (string, bool) {
-	// Normalize
-	s = macCleaner.Replace(s)
-	if len(s) < 6 {
-		return "", false
+	v, err := db.LookupErr(s)
+	return v, err == nil
+}
+
+// LookupErr is like Lookup but reports why a lookup failed: ErrInvalidMAC for
+// malformed input and ErrNotFound for unknown OUIs.
+func (db *DB) LookupErr(s string) (string, error) {
+	d := db.cur.Load()
+	if v, n, digits, ok := cleanPrefix(s); ok && (!d.strict || digits == 6 || digits == 12 || digits == 16) && (digits <= 16 || d.truncate) {
+		return d.lookupValue(v, n)
 	}
-	if len(s) > 6 {
-		s = s[:6]
+	key, _, err := d.normalize(s)
+	if err != nil {
+		return "", err
 	}
-	s = strings.ToLower(s)
+	return d.lookupPrefix(key)
+}
 
-	id, ok := db.entries[s]
-	if !ok || id < 0 {
-		return "", false
+// LookupStrict is LookupErr with WithStrictInput's validation whatever the
+// DB's options: input must be exactly 6, 12 or 16 hex digits after removing
+// separators, else it returns ErrInvalidMAC. Locally administered addresses
+// (bit 0x02 of the first octet) return ErrLocallyAdministered unless an
+// override or special range names them, as registry owners of their
+// prefix are coincidental.
+func (db *DB) LookupStrict(s string) (string, error) {
+	c, err := cleanMAC(s)
+	if err != nil {
+		return "", err
+	}
+	if len(c) != 6 && len(c) != 12 && len(c) != 16 || !allHex(c) {
+		return "", invalidMAC(s, "want exactly 6, 12 or 16 hex digits")
+	}
+	d := db.cur.Load()
+	v, n, _, _ := cleanPrefix(c[:min(len(c), 12)])
+	if a, ok := d.resolve(v, n); ok && a.layer != LayerRegistry {
+		return a.name, nil
+	}
+	if v>>(4*n-8)&0x02 != 0 {
+		return "", ErrLocallyAdministered
+	}
+	return d.lookupValue(v, n)
+}
+
+// LookupN resolves the vendor registered for exactly the first bits bits
+// (24, 28 or 36) of s. Input after the prefix is ignored, so partially
+// redacted MACs such as "b8:27:eb:xx:xx:xx" resolve deterministically.
+func (db *DB) LookupN(s string, bits int) (string, error) {
+	if bits != 24 && bits != 28 && bits != 36 {
+		return "", fmt.Errorf("unsupported prefix length %d (want 24, 28 or 36)", bits)
 	}
-	v, err := db.vendorByID(id)
+	c, err := cleanMAC(s)
 	if err != nil {
-		return "", false
+		return "", err
+	}
+	n := bits / 4
+	if len(c) < n || !allHex(c[:n]) {
+		return "", invalidMAC(s, "fewer than %d hex digits", n)
+	}
+	return db.cur.Load().lookupKey(strings.ToLower(c[:n]))
+}
+
+// Result is one LookupAll result.
+type Result struct {
+	Vendor string
+	OK     bool
+}
+
+// LookupAll resolves macs in one call, returning a Result per input in the
+// same order. Repeated inputs are normalized once, and inputs sharing a
+// prefix share the vendor string.
+func (db *DB) LookupAll(macs []string) []Result {
+	d := db.cur.Load()
+	out := make([]Result, len(macs))
+	byInput := make(map[string]int)
+	byPrefix := make(map[string]Result)
+	for i, s := range macs {
+		if j, ok := byInput[s]; ok {
+			out[i] = out[j]
+			continue
+		}
+		byInput[s] = i
+		key, _, err := d.normalize(s)
+		if err != nil {
+			continue
+		}
+		a, ok := d.resolveKey(key)
+		if !ok {
+			continue
+		}
+		if a.layer != LayerRegistry {
+			out[i] = Result{Vendor: a.name, OK: true}
+			continue
+		}
+		p := key[:a.digits]
+		r, ok := byPrefix[p]
+		if !ok {
+			v, err := d.vendorOf(a.id)
+			r = Result{Vendor: v, OK: err == nil}
+			byPrefix[p] = r
+		}
+		out[i] = r
+	}
+	return out
+}
+
+// All iterates over every prefix and its vendor name in ascending prefix
+// order. Prefixes are 6, 7 or 9 lower-case hex digits.
+func (db *DB) All() iter.Seq2[string, string] {
+	d := db.cur.Load()
+	return func(yield func(string, string) bool) {
+		for _, p := range d.entries.sorted() {
+			v, err := d.lookupKey(p)
+			if err != nil {
+				continue
+			}
+			if !yield(p, v) {
+				return
+			}
+		}
+	}
+}
+
+// lookupPrefix resolves the longest registered prefix of key, so MA-S and
+// MA-M assignments win over the MA-L block they are carved from.
+func (d *dataset) lookupPrefix(key string) (string, error) {
+	v, n, _, ok := cleanPrefix(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return d.lookupValue(v, n)
+}
+
+// lookupValue is lookupPrefix for the n-digit prefix v (n <= 12), answered
+// by the layers in the DB's precedence.
+func (d *dataset) lookupValue(v uint64, n int) (string, error) {
+	a, ok := d.resolve(v, n)
+	if !ok {
+		return "", ErrNotFound
+	}
+	if a.layer != LayerRegistry {
+		return a.name, nil
+	}
+	return d.vendorOf(a.id)
+}
+
+// lookupKey resolves an exact prefix key.
+func (d *dataset) lookupKey(key string) (string, error) {
+	id, ok := d.entries.get(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return d.vendorOf(id)
+}
+
+// vendorOf returns the name of vendor id, treating bad IDs as not found.
+func (d *dataset) vendorOf(id int) (string, error) {
+	if id < 0 {
+		return "", ErrNotFound
+	}
+	v, err := d.vendorByID(id)
+	if err != nil {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+// normalize returns the lookup key for s: its Normalize form cut to 12 hex
+// digits (a MAC-48), and whether Normalize dropped part of s, which is an
+// error unless the DB was opened WithTruncate(true). If the DB is strict,
+// the whole input must be hex digits of a valid length.
+func (d *dataset) normalize(s string) (string, bool, error) {
+	c, err := cleanMAC(s)
+	if err != nil {
+		return "", false, err
+	}
+	if d.strict && (len(c) != 6 && len(c) != 12 && len(c) != 16 || !allHex(c)) {
+		return "", false, invalidMAC(s, "strict mode wants exactly 6, 12 or 16 hex digits")
 	}
-	return v, true
+	n := hexRun(c)
+	if n < len(c) && !d.truncate {
+		return "", false, invalidMAC(s, "%q after the hex digits would be ignored (see WithTruncate)", c[n:])
+	}
+	return strings.ToLower(c[:min(n, 12)]), n < len(c), nil
+}
+
+// extractMAC returns the MAC part of inputs pasted from logs and URLs: the
+// value of a mac= query parameter (or of a lone key=value pair), URL-decoded,
+// without a leading 0x.
+func extractMAC(s string) string {
+	if strings.IndexByte(s, '=') >= 0 {
+		if i := strings.IndexByte(s, '?'); i >= 0 {
+			s = s[i+1:]
+		}
+		params := strings.FieldsFunc(s, func(r rune) bool { return r == '&' || r == ';' })
+		for _, p := range params {
+			k, v, ok := strings.Cut(p, "=")
+			if ok && (strings.EqualFold(strings.TrimSpace(k), "mac") || len(params) == 1) {
+				if u, err := url.QueryUnescape(v); err == nil {
+					v = u
+				}
+				s = strings.TrimSpace(v)
+				break
+			}
+		}
+	}
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		s = s[2:]
+	}
+	return s
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
 }
 
 // LookupFromHardwareAddr returns the vendor for a net.HardwareAddr.
+// It supports 6-byte (MAC-48), 8-byte (EUI-64) and 20-byte (IP over
+// InfiniBand) addresses.
 func (db *DB) LookupFromHardwareAddr(hw net.HardwareAddr) (string, bool) {
-	return db.Lookup(hw.String())
+	v, err := db.LookupFromHardwareAddrErr(hw)
+	return v, err == nil
+}
+
+// LookupFromHardwareAddrErr is like LookupFromHardwareAddr but returns
+// ErrInvalidMAC for addresses of any other length and ErrNotFound for
+// unknown OUIs.
+func (db *DB) LookupFromHardwareAddrErr(hw net.HardwareAddr) (string, error) {
+	var prefix []byte
+	switch len(hw) {
+	case 6, 8:
+		prefix = hw[:6]
+	case 20:
+		// 4 bytes of flags and QPN, 8 bytes of GID subnet prefix, then the
+		// port GUID, an EUI-64 whose first 3 bytes are the OUI.
+		prefix = hw[12:18]
+	default:
+		return "", ErrInvalidMAC
+	}
+	return db.cur.Load().lookupPrefix(hex.EncodeToString(prefix))
 }
 
-func (db *DB) vendorByID(id int) (string, error) {
+// LookupFromIP returns the vendor of the MAC embedded in an IPv6 address
+// whose interface ID was derived from it with modified EUI-64, such as a
+// link-local fe80::/64 or SLAAC address.
+func (db *DB) LookupFromIP(ip net.IP) (string, bool) {
+	v, err := db.LookupFromIPErr(ip)
+	return v, err == nil
+}
+
+// LookupFromIPErr is like LookupFromIP but returns ErrInvalidMAC for IPv4
+// addresses and interface IDs without the EUI-64 ff:fe marker (e.g. privacy
+// addresses), and ErrNotFound for unknown OUIs.
+func (db *DB) LookupFromIPErr(ip net.IP) (string, error) {
+	hw, ok := macFromIP(ip)
+	if !ok {
+		return "", ErrInvalidMAC
+	}
+	return db.LookupFromHardwareAddrErr(hw)
+}
+
+// macFromIP reverses modified EUI-64: it drops the ff:fe inserted in the
+// middle of the interface ID and flips the universal/local bit back.
+func macFromIP(ip net.IP) (net.HardwareAddr, bool) {
+	if len(ip) != net.IPv6len || ip.To4() != nil {
+		return nil, false
+	}
+	iid := ip[8:]
+	if iid[3] != 0xff || iid[4] != 0xfe {
+		return nil, false
+	}
+	return net.HardwareAddr{iid[0] ^ 0x02, iid[1], iid[2], iid[5], iid[6], iid[7]}, true
+}
+
+func (d *dataset) vendorByID(id int) (string, error) {
 	// ids map directly to offsets array indices
 	idx := id
-	if idx < 0 || idx+1 >= len(db.offsets) {
+	if idx < 0 || idx+1 >= len(d.offsets) {
 		return "", fmt.Errorf("id out of range")
 	}
-	start := db.offsets[idx]
-	end := db.offsets[idx+1]
-	if start < 0 || end < start || int(end) > len(db.vendors) {
+	start := d.offsets[idx]
+	end := d.offsets[idx+1]
+	if start < 0 || end < start || int(end) > len(d.vendors) {
 		// Fallback: scan to newline
-		if int(start) >= len(db.vendors) {
+		if int(start) >= len(d.vendors) {
 			return "", fmt.Errorf("offset out of range")
 		}
-		b := db.vendors[start:]
-		if i := bytes.IndexByte(b, '\n'); i >= 0 {
-			return string(bytes.TrimRight(b[:i], "\r\n")), nil
+		b := d.vendors[start:]
+		if i := strings.IndexByte(b, '\n'); i >= 0 {
+			return strings.TrimRight(b[:i], "\r\n"), nil
 		}
-		return string(bytes.TrimRight(b, "\r\n")), nil
+		return strings.TrimRight(b, "\r\n"), nil
 	}
-	line := db.vendors[start:end]
+	line := d.vendors[start:end]
 	// Offsets are written after each line including the newline
-	line = bytes.TrimRight(line, "\r\n")
-	return string(line), nil
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// defaultDataDir returns $PG_OUI_DATA_DIR, falling back to the user's cache
+// dir (pg-oui) and finally the current directory.
+func defaultDataDir() string {
+	if env := os.Getenv("PG_OUI_DATA_DIR"); env != "" {
+		return env
+	}
+	if cdir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(cdir, "pg-oui")
+	}
+	return "."
+}
+
+// hasDataset reports whether fsys holds a binary or three-file dataset.
+func hasDataset(fsys fs.FS, cfg *openCfg) bool {
+	for _, names := range [][]string{{BinaryName}, {cfg.entriesName, cfg.vendorsName, cfg.indexName}} {
+		ok := true
+		for _, n := range names {
+			if st, err := fs.Stat(fsys, n); err != nil || st.IsDir() {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
 }
 
 // Default DB singleton and wrappers
 var (
-	defOnce sync.Once
-	defDB   *DB
-	defErr  error
+	defMu  sync.Mutex                     // serializes opening and ResetDefault
+	defCur atomic.Pointer[defaultDBState] // nil until first use or after ResetDefault
 )
 
+type defaultDBState struct {
+	db  *DB
+	err error
+}
+
 func defaultDB() (*DB, error) {
-	defOnce.Do(func() {
-		// Allow overriding the data directory via env var
-		if dir := os.Getenv("PG_OUI_DATA_DIR"); dir != "" {
-			defDB, defErr = Open(WithDir(dir))
-			return
-		}
-		defDB, defErr = Open() // current directory
-	})
-	return defDB, defErr
+	if st := defCur.Load(); st != nil {
+		return st.db, st.err
+	}
+	defMu.Lock()
+	defer defMu.Unlock()
+	if st := defCur.Load(); st != nil {
+		return st.db, st.err
+	}
+	st := &defaultDBState{}
+	// Allow overriding the data directory via env var
+	if dir := os.Getenv("PG_OUI_DATA_DIR"); dir != "" {
+		st.db, st.err = Open(WithDir(dir))
+	} else {
+		st.db, st.err = Open() // current directory
+	}
+	defCur.Store(st)
+	return st.db, st.err
+}
+
+// ResetDefault makes the package-level helpers (Lookup, LookupE,
+// SearchVendor, SearchVendorFromMAC and SearchVendorIn) open their DBs again
+// on next use, so a changed PG_OUI_DATA_DIR or working directory takes
+// effect without a restart. Lookups running concurrently finish on the old
+// DB.
+func ResetDefault() {
+	defMu.Lock()
+	defer defMu.Unlock()
+	if st := defCur.Swap(nil); st != nil && st.db != nil {
+		st.db.Close()
+	}
+	dirDBs.Clear()
 }
 
 // Lookup is a package-level helper that uses a default DB.
@@ -239,15 +786,54 @@ func Lookup(s string) (string, bool) {
 	return db.Lookup(s)
 }
 
+// LookupE is like Lookup but returns the full Record, and the error from
+// loading the default DB when it could not be opened, so a missing dataset
+// is distinguishable from ErrNotFound.
+func LookupE(s string) (Record, error) {
+	db, err := defaultDB()
+	if err != nil {
+		return Record{}, fmt.Errorf("open default DB: %w", err)
+	}
+	return db.LookupRecord(s)
+}
+
 // SearchVendor keeps backward compatibility by returning string only.
 func SearchVendor(s string) string {
 	v, _ := Lookup(s)
 	return v
 }
 
+// dirDBs caches the DBs opened by SearchVendorIn, keyed by directory.
+var dirDBs sync.Map // string -> *dirDB
+
+type dirDB struct {
+	once sync.Once
+	db   *DB
+	err  error
+}
+
+// SearchVendorIn is like SearchVendor but reads the dataset in dir instead of
+// depending on the working directory or environment. The DB for each dir is
+// opened once and cached for the life of the process; it returns "" if the
+// dataset cannot be opened.
+func SearchVendorIn(dir, mac string) string {
+	e, _ := dirDBs.LoadOrStore(dir, &dirDB{})
+	d := e.(*dirDB)
+	d.once.Do(func() { d.db, d.err = Open(WithDir(dir)) })
+	if d.err != nil {
+		return ""
+	}
+	v, _ := d.db.Lookup(mac)
+	return v
+}
+
 // SearchVendorFromMAC is a compatibility wrapper using the default DB.
 func SearchVendorFromMAC(hw net.HardwareAddr) string {
-	v, _ := Lookup(hw.String())
+	db, err := defaultDB()
+	if err != nil {
+		return ""
+	}
+	v, _ := db.LookupFromHardwareAddr(hw)
 	return v
 }
 
@@ -0,0 +1,73 @@
+package pg_oui
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"time"
+)
+
+// DeviceEvent reports a device Watch has seen on the network.
+type DeviceEvent struct {
+	Time      time.Time
+	Interface string // name of the interface it was seen on
+	IP        net.IP
+	MAC       net.HardwareAddr
+	// Record is the registry entry of MAC; Record.Vendor is empty if the
+	// OUI is unknown or MAC is locally administered.
+	Record Record
+}
+
+// Watch passively discovers devices on iface ("" for all interfaces) and
+// calls fn once for each new IP/MAC pair, starting with the devices already
+// known, until ctx is done. It reads the kernel's neighbor (ARP and NDP)
+// table, so it needs no special privileges but only sees hosts this machine
+// exchanges packets with. fn runs in the calling goroutine.
+//
+// Watch is implemented on Linux (rtnetlink); elsewhere it returns
+// errors.ErrUnsupported.
+func (db *DB) Watch(ctx context.Context, iface string, fn func(DeviceEvent)) error {
+	index := 0
+	if iface != "" {
+		ifi, err := net.InterfaceByName(iface)
+		if err != nil {
+			return err
+		}
+		index = ifi.Index
+	}
+	type device struct {
+		index int
+		ip    string
+	}
+	seen := map[device]string{}
+	report := func(n neighbor) {
+		// Skip other interfaces, and broadcast, multicast and all-zero
+		// entries, which are no devices.
+		if index != 0 && n.index != index || n.mac[0]&1 != 0 || bytes.Count(n.mac, []byte{0}) == len(n.mac) {
+			return
+		}
+		key := device{n.index, n.ip.String()}
+		if seen[key] == n.mac.String() {
+			return
+		}
+		seen[key] = n.mac.String()
+		ev := DeviceEvent{Time: time.Now(), IP: n.ip, MAC: n.mac}
+		if ifi, err := net.InterfaceByIndex(n.index); err == nil {
+			ev.Interface = ifi.Name
+		}
+		// The registry owner of a locally administered MAC's prefix is
+		// coincidental (e.g. randomized phone addresses).
+		if n.mac[0]&0x02 == 0 {
+			ev.Record, _ = db.LookupRecord(n.mac.String())
+		}
+		fn(ev)
+	}
+	return watchNeighbors(ctx, report)
+}
+
+// neighbor is one entry of the kernel neighbor table.
+type neighbor struct {
+	index int // interface index
+	ip    net.IP
+	mac   net.HardwareAddr
+}
@@ -0,0 +1,99 @@
+package pg_oui
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	rtmgrpNeigh   = 0x4 // rtnetlink multicast group for neighbor changes
+	ndaDst        = 1   // neighbor attribute: protocol address
+	ndaLLAddr     = 2   // neighbor attribute: link-layer address
+	sizeofNdMsg   = 12
+	nudIncomplete = 0x01
+	nudFailed     = 0x20
+)
+
+// watchNeighbors reports the current neighbor table, then every neighbor
+// added or updated, until ctx is done.
+func watchNeighbors(ctx context.Context, fn func(neighbor)) error {
+	// Subscribe before dumping the table so no change falls in between.
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return fmt.Errorf("netlink socket: %w", err)
+	}
+	defer syscall.Close(fd)
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: rtmgrpNeigh}); err != nil {
+		return fmt.Errorf("netlink bind: %w", err)
+	}
+	// Wake up regularly to check ctx.
+	tv := syscall.NsecToTimeval(int64(500 * time.Millisecond))
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return fmt.Errorf("netlink timeout: %w", err)
+	}
+
+	dump, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_UNSPEC)
+	if err != nil {
+		return fmt.Errorf("dump neighbors: %w", err)
+	}
+	if err := parseNeighbors(dump, fn); err != nil {
+		return err
+	}
+	buf := make([]byte, 1<<16)
+	for ctx.Err() == nil {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) {
+				continue
+			}
+			return fmt.Errorf("netlink receive: %w", err)
+		}
+		if err := parseNeighbors(buf[:n], fn); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// parseNeighbors calls fn for every reachable (or stale, delayed, ...)
+// neighbor in the RTM_NEWNEIGH messages in b.
+func parseNeighbors(b []byte, fn func(neighbor)) error {
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return fmt.Errorf("netlink parse: %w", err)
+	}
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < sizeofNdMsg {
+			continue
+		}
+		state := binary.NativeEndian.Uint16(m.Data[8:])
+		if state&(nudIncomplete|nudFailed) != 0 {
+			continue
+		}
+		n := neighbor{index: int(int32(binary.NativeEndian.Uint32(m.Data[4:])))}
+		for a := m.Data[sizeofNdMsg:]; len(a) >= syscall.SizeofRtAttr; {
+			l := int(binary.NativeEndian.Uint16(a))
+			if l < syscall.SizeofRtAttr || l > len(a) {
+				break
+			}
+			v := a[syscall.SizeofRtAttr:l]
+			switch binary.NativeEndian.Uint16(a[2:]) {
+			case ndaDst:
+				n.ip = net.IP(bytes.Clone(v))
+			case ndaLLAddr:
+				n.mac = net.HardwareAddr(bytes.Clone(v))
+			}
+			a = a[min(len(a), (l+syscall.RTA_ALIGNTO-1)&^(syscall.RTA_ALIGNTO-1)):]
+		}
+		if n.ip != nil && len(n.mac) == 6 {
+			fn(n)
+		}
+	}
+	return nil
+}
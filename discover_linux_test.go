@@ -0,0 +1,45 @@
+package pg_oui
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+)
+
+// neighMsg returns an RTM_NEWNEIGH message for ip/mac on ifindex in state.
+func neighMsg(ifindex int, state uint16, ip net.IP, mac net.HardwareAddr) []byte {
+	ne := binary.NativeEndian
+	body := make([]byte, sizeofNdMsg)
+	ne.PutUint32(body[4:], uint32(ifindex))
+	ne.PutUint16(body[8:], state)
+	attr := func(typ uint16, v []byte) {
+		a := ne.AppendUint16(ne.AppendUint16(nil, uint16(syscall.SizeofRtAttr+len(v))), typ)
+		a = append(a, v...)
+		for len(a)%syscall.RTA_ALIGNTO != 0 {
+			a = append(a, 0)
+		}
+		body = append(body, a...)
+	}
+	attr(ndaDst, ip)
+	attr(ndaLLAddr, mac)
+	msg := ne.AppendUint32(nil, uint32(syscall.NLMSG_HDRLEN+len(body)))
+	msg = ne.AppendUint16(msg, syscall.RTM_NEWNEIGH)
+	msg = append(msg, make([]byte, 10)...) // flags, seq, pid
+	return append(msg, body...)
+}
+
+func TestParseNeighbors(t *testing.T) {
+	mac, _ := net.ParseMAC("b8:27:eb:01:02:03")
+	ip := net.ParseIP("10.0.0.7").To4()
+	var b []byte
+	b = append(b, neighMsg(3, 0x02, ip, mac)...)                               // reachable
+	b = append(b, neighMsg(3, nudFailed, net.IPv4(10, 0, 0, 8).To4(), mac)...) // failed
+	var got []neighbor
+	if err := parseNeighbors(b, func(n neighbor) { got = append(got, n) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].index != 3 || !got[0].ip.Equal(ip) || got[0].mac.String() != mac.String() {
+		t.Errorf("got %+v, want one reachable neighbor 10.0.0.7 on index 3", got)
+	}
+}
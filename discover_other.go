@@ -0,0 +1,10 @@
+//go:build !linux
+
+package pg_oui
+
+import (
+	"context"
+	"errors"
+)
+
+func watchNeighbors(_ context.Context, _ func(neighbor)) error { return errors.ErrUnsupported }
@@ -0,0 +1,181 @@
+package pg_oui
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// dumpFormat identifies the DumpJSON document format.
+const dumpFormat = "pg-oui-dump/1"
+
+// dump is the DumpJSON document.
+type dump struct {
+	dumpHead
+	Overrides []dumpOverride `json:"overrides"`
+	Entries   []dumpEntry    `json:"entries"`
+}
+
+// dumpHead is the part of dump that DumpJSON writes as one object.
+type dumpHead struct {
+	Format        string      `json:"format"`
+	Info          DatasetInfo `json:"info"`
+	StrictInput   bool        `json:"strict_input"`
+	Truncate      bool        `json:"truncate"`
+	SpecialRanges bool        `json:"special_ranges"`
+	Precedence    []Layer     `json:"precedence"`
+}
+
+type dumpOverride struct {
+	Prefix string `json:"prefix"`
+	Name   string `json:"name"`
+}
+
+type dumpEntry struct {
+	Prefix    string `json:"prefix"`
+	Vendor    string `json:"vendor"`
+	Registry  string `json:"registry,omitempty"`
+	RawName   string `json:"raw_name,omitempty"`
+	Address   string `json:"address,omitempty"`
+	ShortName string `json:"short_name,omitempty"`
+}
+
+// DumpJSON writes everything that decides the DB's answers as JSON: the
+// dataset info, the lookup options (strict input, truncation, special
+// ranges, precedence), the overrides and every prefix with its
+// vendor (and v2 record fields), sorted, one per line so that the dumps of
+// two environments can be compared with diff. LoadJSON reads it back.
+func (db *DB) DumpJSON(w io.Writer) error {
+	d := db.cur.Load()
+	doc := dump{dumpHead: dumpHead{Format: dumpFormat, Info: d.info, StrictInput: d.strict, Truncate: d.truncate, SpecialRanges: !d.noSpecial, Precedence: d.precedence}}
+	for k, name := range d.overrides {
+		doc.Overrides = append(doc.Overrides, dumpOverride{Prefix: formatKey(k>>4, int(k&0xf)), Name: name})
+	}
+	slices.SortFunc(doc.Overrides, func(a, b dumpOverride) int { return cmp.Compare(a.Prefix, b.Prefix) })
+	for _, p := range d.entries.sorted() {
+		if _, ok := parseKey(p); !ok || len(p) < 6 {
+			continue // lookups never reach it
+		}
+		v, err := d.lookupKey(p)
+		if err != nil {
+			continue
+		}
+		e := dumpEntry{Prefix: p, Vendor: v}
+		if extra := d.records[p]; len(extra) >= 3 {
+			e.Registry, e.RawName, e.Address = extra[0], extra[1], extra[2]
+			if len(extra) >= 4 {
+				e.ShortName = extra[3]
+			}
+		}
+		doc.Entries = append(doc.Entries, e)
+	}
+
+	bw := bufio.NewWriter(w)
+	head, err := json.MarshalIndent(doc.dumpHead, "", "  ")
+	if err != nil {
+		return err
+	}
+	bw.Write(head[:len(head)-2]) // without the closing "\n}"
+	if err := writeDumpList(bw, "overrides", doc.Overrides, false); err != nil {
+		return err
+	}
+	if err := writeDumpList(bw, "entries", doc.Entries, true); err != nil {
+		return err
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// writeDumpList writes the field name of the DumpJSON document, with one
+// element of list per line.
+func writeDumpList[T any](w *bufio.Writer, name string, list []T, last bool) error {
+	fmt.Fprintf(w, ",\n  %q: [", name)
+	for i, v := range list {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString("\n    ")
+		w.Write(b)
+	}
+	if len(list) > 0 {
+		w.WriteString("\n  ")
+	}
+	w.WriteString("]")
+	if last {
+		w.WriteString("\n")
+	}
+	return nil
+}
+
+// LoadJSON returns a DB that answers exactly like the one whose DumpJSON
+// output r holds. Its Metadata source is "json"; Reload keeps the data.
+func LoadJSON(r io.Reader) (*DB, error) {
+	var doc dump
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse dump: %w", err)
+	}
+	if doc.Format != dumpFormat {
+		return nil, fmt.Errorf("parse dump: unknown format %q (want %q)", doc.Format, dumpFormat)
+	}
+	if err := checkPrecedence(doc.Precedence); err != nil {
+		return nil, fmt.Errorf("parse dump: %w", err)
+	}
+
+	entries := make(map[string]int, len(doc.Entries))
+	ids := make(map[string]int)
+	var vendors strings.Builder
+	offsets := []int64{0}
+	var records map[string][]string
+	for _, e := range doc.Entries {
+		if _, ok := parseKey(e.Prefix); !ok || len(e.Prefix) < 6 {
+			return nil, fmt.Errorf("parse dump: bad prefix %q", e.Prefix)
+		}
+		if e.Vendor == "" || strings.ContainsAny(e.Vendor, "\r\n") {
+			return nil, fmt.Errorf("parse dump: bad vendor %q for %s", e.Vendor, e.Prefix)
+		}
+		id, ok := ids[e.Vendor]
+		if !ok {
+			id = len(ids)
+			ids[e.Vendor] = id
+			vendors.WriteString(e.Vendor)
+			vendors.WriteByte('\n')
+			offsets = append(offsets, int64(vendors.Len()))
+		}
+		entries[e.Prefix] = id
+		if e.Registry != "" || e.RawName != "" || e.Address != "" || e.ShortName != "" {
+			if records == nil {
+				records = make(map[string][]string)
+			}
+			records[e.Prefix] = []string{e.Registry, e.RawName, e.Address, e.ShortName}
+		}
+	}
+	var overrides map[uint64]string
+	for _, o := range doc.Overrides {
+		v, n, digits, ok := cleanPrefix(o.Prefix)
+		if !ok || digits > 12 || o.Name == "" {
+			return nil, fmt.Errorf("parse dump: bad override %q", o.Prefix)
+		}
+		if overrides == nil {
+			overrides = make(map[uint64]string)
+		}
+		overrides[overrideKey(v, n)] = o.Name
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("parse dump: no entries")
+	}
+
+	d := &dataset{entries: newPrefixTable(entries), vendors: vendors.String(), offsets: offsets, strict: doc.StrictInput, truncate: doc.Truncate, noSpecial: !doc.SpecialRanges, overrides: overrides, precedence: doc.Precedence, source: "json", records: records, info: doc.Info}
+	db := &DB{open: func(context.Context) (*dataset, error) { return d, nil }, stop: make(chan struct{})}
+	db.cur.Store(d)
+	return db, nil
+}
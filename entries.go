@@ -0,0 +1,97 @@
+package pg_oui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Entries file formats. v1 is plain "OUI,VendorID" CSV. v2 starts with
+// entriesV2Header and has tab-separated "OUI, VendorID[, extra...]" lines,
+// so extra fields may contain commas; Open detects the format from the
+// first line.
+const (
+	EntriesV1 = "v1"
+	EntriesV2 = "v2"
+
+	entriesV2Header = "#pg-oui entries v2"
+)
+
+// readEntries parses a v1 or v2 entries file and calls add for every entry
+// with a numeric vendor ID; malformed lines are skipped. extra holds the
+// fields after the vendor ID (v2 only).
+func readEntries(r io.Reader, add func(oui string, id int, extra []string)) error {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(entriesV2Header)); string(head) != entriesV2Header {
+		reader := csv.NewReader(br)
+		reader.FieldsPerRecord = -1
+		for {
+			rec, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if len(rec) < 2 {
+				continue
+			}
+			// vendorID must be numeric; ignore malformed
+			if id, err := atoi(rec[1]); err == nil {
+				add(rec[0], id, nil)
+			}
+		}
+	}
+	sc := bufio.NewScanner(br)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := bytes.TrimRight(sc.Bytes(), "\r")
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		f := strings.Split(string(line), "\t")
+		if len(f) < 2 {
+			continue
+		}
+		if id, err := atoi(f[1]); err == nil {
+			add(f[0], id, f[2:])
+		}
+	}
+	return sc.Err()
+}
+
+// encodeEntries writes entries in the given format ("" means v1). extra, if
+// non-nil, returns the extra fields of entry i (v2 only).
+func encodeEntries(w io.Writer, format string, n int, entry func(i int) (oui string, id int), extra func(i int) []string) error {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case "", EntriesV1:
+		for i := 0; i < n; i++ {
+			oui, id := entry(i)
+			fmt.Fprintf(bw, "%s,%d\n", oui, id)
+		}
+	case EntriesV2:
+		bw.WriteString(entriesV2Header + "\n")
+		for i := 0; i < n; i++ {
+			oui, id := entry(i)
+			fmt.Fprintf(bw, "%s\t%d", oui, id)
+			if extra != nil {
+				for _, f := range extra(i) {
+					bw.WriteByte('\t')
+					bw.WriteString(tsvField.Replace(f))
+				}
+			}
+			bw.WriteByte('\n')
+		}
+	default:
+		return fmt.Errorf("unsupported entries format %q", format)
+	}
+	return bw.Flush()
+}
+
+// tsvField keeps a value on one line and in one field.
+var tsvField = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
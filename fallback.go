@@ -0,0 +1,111 @@
+package pg_oui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Source is one location WithFallback may load the dataset from.
+type Source struct {
+	Name string // reported as Metadata().Source when this source wins
+	FS   fs.FS
+}
+
+// FSSource returns a Source reading from fsys, e.g. an embedded snapshot.
+func FSSource(name string, fsys fs.FS) Source { return Source{Name: name, FS: fsys} }
+
+// DirSource returns a Source reading from a local directory.
+func DirSource(dir string) Source { return Source{Name: "dir:" + dir, FS: os.DirFS(dir)} }
+
+// HTTPSource returns a Source fetching the data files from baseURL, such as
+// a directory written by `pg-oui update -publish` behind a web server. A nil
+// client uses the one of WithHTTPClient.
+func HTTPSource(baseURL string, client *http.Client) Source {
+	return Source{Name: baseURL, FS: &httpFS{base: strings.TrimSuffix(baseURL, "/"), client: client}}
+}
+
+// WithFallback makes Open try each source in order and use the first that
+// holds a usable dataset; the winner is recorded in Metadata. The FS, dir
+// and auto-update options are ignored when sources are given.
+func WithFallback(sources ...Source) Option { return func(c *openCfg) { c.fallback = sources } }
+
+func openFallback(ctx context.Context, cfg *openCfg) (*dataset, error) {
+	var errs []error
+	for _, s := range cfg.fallback {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fsys := s.FS
+		if h, ok := fsys.(*httpFS); ok && h.client == nil {
+			fsys = &httpFS{base: h.base, client: defaultClient(cfg)}
+		}
+		d, err := load(cfg, fsys, s.Name)
+		if err == nil {
+			return d, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+	}
+	return nil, fmt.Errorf("no usable dataset: %w", errors.Join(errs...))
+}
+
+// defaultClient returns the client of WithHTTPClient, or one with a 30s
+// timeout.
+func defaultClient(cfg *openCfg) *http.Client {
+	if cfg.httpClient != nil {
+		return cfg.httpClient
+	}
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// httpFS is a read-only fs.FS that GETs each file below base.
+type httpFS struct {
+	base   string
+	client *http.Client
+}
+
+func (h *httpFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	resp, err := h.client.Get(h.base + "/" + name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case resp.StatusCode != http.StatusOK:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fmt.Errorf("status %d", resp.StatusCode)}
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &memFile{Reader: bytes.NewReader(b), name: name, size: int64(len(b))}, nil
+}
+
+// memFile is an fs.File over bytes already in memory.
+type memFile struct {
+	*bytes.Reader
+	name string
+	size int64
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *memFile) Close() error               { return nil }
+
+func (f *memFile) Name() string       { return f.name }
+func (f *memFile) Size() int64        { return f.size }
+func (f *memFile) Mode() fs.FileMode  { return 0o444 }
+func (f *memFile) ModTime() time.Time { return time.Time{} }
+func (f *memFile) IsDir() bool        { return false }
+func (f *memFile) Sys() any           { return nil }
@@ -0,0 +1,110 @@
+package pg_oui
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOpen_Fallback(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	db, err := Open(WithFallback(
+		FSSource("empty", fstest.MapFS{}),
+		DirSource(t.TempDir()),
+		HTTPSource(srv.URL, nil),
+	))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got := db.Metadata().Source; got != srv.URL {
+		t.Errorf("source = %q, want %q", got, srv.URL)
+	}
+	if v, _ := db.Lookup("00:11:22"); v != "Sony" {
+		t.Errorf("lookup = %q, want Sony", v)
+	}
+
+	_, err = Open(WithFallback(FSSource("empty", fstest.MapFS{})))
+	if err == nil || !strings.Contains(err.Error(), "empty:") {
+		t.Fatalf("want error naming the failed source, got %v", err)
+	}
+}
+
+// countingTransport counts the requests of a WithHTTPClient client.
+type countingTransport struct{ n int }
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.n++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestOpen_FallbackHTTPClient(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	tr := &countingTransport{}
+	if _, err := Open(WithHTTPClient(&http.Client{Transport: tr}), WithFallback(HTTPSource(srv.URL, nil))); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if tr.n == 0 {
+		t.Error("WithHTTPClient client not used by HTTPSource")
+	}
+	if got := mirrorURLs("https://mirror.example/ieee/", []string{"https://standards-oui.ieee.org/oui28/mam.csv"}); got[0] != "https://mirror.example/ieee/mam.csv" {
+		t.Errorf("mirrorURLs = %v", got)
+	}
+}
+
+func TestFetchURL(t *testing.T) {
+	if _, ok := fetchers["mem"]; !ok { // -count > 1
+		RegisterFetcher("Mem", FetcherFunc(func(_ context.Context, url string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(strings.TrimPrefix(url, "mem:"))), nil
+		}))
+	}
+	path := filepath.Join(t.TempDir(), "oui.csv")
+	if err := os.WriteFile(path, []byte(testCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for url, want := range map[string]string{"mem:abc": "abc", "MEM:x": "MEM:x", path: testCSV, "file://" + path: testCSV} {
+		rc, err := FetchURL(context.Background(), nil, url)
+		if err != nil {
+			t.Errorf("FetchURL(%q): %v", url, err)
+			continue
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(b) != want {
+			t.Errorf("FetchURL(%q) = %q, want %q", url, b, want)
+		}
+	}
+	if _, err := FetchURL(context.Background(), nil, "s3://bucket/oui.csv"); err == nil {
+		t.Error("want error for a scheme without Fetcher")
+	}
+}
+
+func TestOpenContext_Cancelled(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := OpenContext(ctx, WithFallback(DirSource(dir))); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package pg_oui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Fetcher retrieves raw registry data, such as an IEEE CSV or a Wireshark
+// manuf file, from a URL for the runtime auto-update and update_data.
+// http and https URLs are fetched with the client of WithHTTPClient and
+// file URLs (or plain paths) from disk; other schemes, e.g. s3 or oci, need
+// a Fetcher added with RegisterFetcher.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (io.ReadCloser, error)
+}
+
+// FetcherFunc adapts a function to the Fetcher interface.
+type FetcherFunc func(ctx context.Context, url string) (io.ReadCloser, error)
+
+func (f FetcherFunc) Fetch(ctx context.Context, url string) (io.ReadCloser, error) {
+	return f(ctx, url)
+}
+
+var (
+	fetchersMu sync.RWMutex
+	fetchers   = map[string]Fetcher{"": FetcherFunc(fetchFile), "file": FetcherFunc(fetchFile)}
+)
+
+// RegisterFetcher makes f fetch the URLs with the given scheme, compared
+// case-insensitively, so WithSourceURL, WithDownloadURL and update_data
+// -url accept them. It panics if the scheme is http, https or already
+// registered; call it from an init function.
+func RegisterFetcher(scheme string, f Fetcher) {
+	scheme = strings.ToLower(scheme)
+	fetchersMu.Lock()
+	defer fetchersMu.Unlock()
+	if _, dup := fetchers[scheme]; dup || isHTTPScheme(scheme) || f == nil {
+		panic("pg_oui: RegisterFetcher called twice or with nil for scheme " + scheme)
+	}
+	fetchers[scheme] = f
+}
+
+// FetchURL returns the data at rawURL from the Fetcher of its scheme; http
+// and https URLs are fetched with client (nil for one with a 30s timeout).
+func FetchURL(ctx context.Context, client *http.Client, rawURL string) (io.ReadCloser, error) {
+	scheme := urlScheme(rawURL)
+	if isHTTPScheme(scheme) {
+		if client == nil {
+			client = defaultClient(&openCfg{})
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return resp.Body, nil
+	}
+	fetchersMu.RLock()
+	f, ok := fetchers[scheme]
+	fetchersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no Fetcher registered for scheme %q", scheme)
+	}
+	return f.Fetch(ctx, rawURL)
+}
+
+// urlScheme returns the lower-case scheme of rawURL, or "" for a plain path.
+func urlScheme(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Scheme)
+}
+
+func isHTTPScheme(scheme string) bool { return scheme == "http" || scheme == "https" }
+
+// fetchFile opens a file: URL or a plain path.
+func fetchFile(_ context.Context, rawURL string) (io.ReadCloser, error) {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Scheme != "" {
+		path = u.Path
+	}
+	return os.Open(path)
+}
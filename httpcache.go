@@ -0,0 +1,77 @@
+package pg_oui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// SourceCacheName stores the HTTP validators of the downloaded sources in
+// the data dir.
+const SourceCacheName = "sources.json"
+
+// SourceCache maps source URLs to the ETag and Last-Modified they were last
+// downloaded with, so refreshes can skip unchanged upstream files.
+type SourceCache map[string]CacheValidators
+
+// CacheValidators are the validators of one downloaded source.
+type CacheValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// ReadSourceCache returns the source cache in dir. A missing file yields an
+// empty cache.
+func ReadSourceCache(dir string) (SourceCache, error) {
+	c := SourceCache{}
+	b, err := os.ReadFile(filepath.Join(dir, SourceCacheName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read source cache: %w", err)
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parse source cache: %w", err)
+	}
+	return c, nil
+}
+
+// Write stores c in dir.
+func (c SourceCache) Write(dir string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode source cache: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, SourceCacheName), append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write source cache: %w", err)
+	}
+	return nil
+}
+
+// Condition adds If-None-Match and If-Modified-Since to req from the
+// validators recorded for its URL, if any. The server then answers 304 Not
+// Modified when the file is unchanged.
+func (c SourceCache) Condition(req *http.Request) {
+	v := c[req.URL.String()]
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+// Record stores the validators of a 200 response to url.
+func (c SourceCache) Record(url string, resp *http.Response) {
+	v := CacheValidators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if v == (CacheValidators{}) {
+		delete(c, url)
+		return
+	}
+	c[url] = v
+}
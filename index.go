@@ -0,0 +1,65 @@
+package pg_oui
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Index footer layout (versioned indexes only), appended after the offsets:
+//
+//	uint32 version | uint32 CRC-32 (IEEE) of the vendors file | "PGOUIIDX"
+//
+// Legacy indexes have no footer; the magic can never be a valid offset.
+const (
+	indexMagic      = "PGOUIIDX"
+	indexFooterSize = 16
+	indexVersion    = 1
+)
+
+// splitIndex separates the offsets from the footer of a (possibly legacy)
+// index and verifies the footer against the vendors file contents.
+func splitIndex(index, vendors []byte) ([]byte, error) {
+	n := len(index)
+	if n < indexFooterSize || string(index[n-len(indexMagic):]) != indexMagic {
+		return index, nil
+	}
+	footer := index[n-indexFooterSize:]
+	if v := binary.LittleEndian.Uint32(footer[0:4]); v != indexVersion {
+		return nil, fmt.Errorf("unsupported index version %d", v)
+	}
+	if want, got := binary.LittleEndian.Uint32(footer[4:8]), crc32.ChecksumIEEE(vendors); want != got {
+		return nil, fmt.Errorf("index checksum mismatch: vendors file (crc32 %08x) does not match index (crc32 %08x)", got, want)
+	}
+	return index[:n-indexFooterSize], nil
+}
+
+// writeIndex writes the offsets of each line in vendors followed by a
+// versioned footer.
+func writeIndex(w io.Writer, vendors []byte) error {
+	var off int64
+	if err := binary.Write(w, binary.LittleEndian, off); err != nil {
+		return fmt.Errorf("write initial offset: %w", err)
+	}
+	for len(vendors[off:]) > 0 {
+		i := bytes.IndexByte(vendors[off:], '\n')
+		if i < 0 {
+			off = int64(len(vendors))
+		} else {
+			off += int64(i + 1)
+		}
+		if err := binary.Write(w, binary.LittleEndian, off); err != nil {
+			return fmt.Errorf("write offset: %w", err)
+		}
+	}
+	var footer [indexFooterSize]byte
+	binary.LittleEndian.PutUint32(footer[0:4], indexVersion)
+	binary.LittleEndian.PutUint32(footer[4:8], crc32.ChecksumIEEE(vendors))
+	copy(footer[8:], indexMagic)
+	if _, err := w.Write(footer[:]); err != nil {
+		return fmt.Errorf("write index footer: %w", err)
+	}
+	return nil
+}
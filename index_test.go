@@ -0,0 +1,317 @@
+package pg_oui
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeVersionedIndex(t *testing.T, dir string) {
+	t.Helper()
+	vendors, err := os.ReadFile(filepath.Join(dir, "vendors"))
+	if err != nil {
+		t.Fatalf("read vendors: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeIndex(&buf, vendors); err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vendors.index"), buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+}
+
+func TestIndexFooter_Verified(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One", "Vendor Two"})
+	writeEntries(t, dir, map[string]int{"abcdef": 0, "abcd12": 1})
+	writeVersionedIndex(t, dir)
+
+	db, err := Open(WithDir(dir), WithAutoUpdate(false))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if v, ok := db.Lookup("abcd12"); !ok || v != "Vendor Two" {
+		t.Fatalf("want 'Vendor Two', got %q ok=%v", v, ok)
+	}
+
+	// A vendors file from another build must be rejected, not misread.
+	writeVendorsOnly(t, dir, "Other One\nOther Two\n")
+	if _, err := Open(WithDir(dir), WithAutoUpdate(false)); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("want checksum mismatch, got %v", err)
+	}
+}
+
+func writeVendorsOnly(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "vendors"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write vendors: %v", err)
+	}
+}
+
+func TestBinaryDataset(t *testing.T) {
+	dir := t.TempDir()
+	csv := testCSV + "MA-S,70B3D5123,Small Vendor,Addr\n"
+	if _, err := Build(strings.NewReader(csv), dir, &BuildOptions{Binary: true}); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultEntries)); !os.IsNotExist(err) {
+		t.Fatalf("entries file written alongside %s", BinaryName)
+	}
+	db, err := Open(WithDir(dir), WithValidation(ValidateThorough))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for mac, want := range map[string]string{"00:11:22:33:44:55": "Sony", "70:b3:d5:12:34:56": "Small Vendor"} {
+		if got, _ := db.Lookup(mac); got != want {
+			t.Errorf("%s: got %q, want %q", mac, got, want)
+		}
+	}
+
+	b, _ := os.ReadFile(filepath.Join(dir, BinaryName))
+	b[len(b)-1] ^= 1
+	if err := os.WriteFile(filepath.Join(dir, BinaryName), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(WithDir(dir)); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("want checksum error, got %v", err)
+	}
+}
+
+func TestDB_ReloadAndWatch(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	reloadErrs := make(chan error, 1)
+	onErr := func(err error) {
+		select {
+		case reloadErrs <- err:
+		default:
+		}
+	}
+	db, err := Open(WithDir(dir), WithWatch(10*time.Millisecond), WithOnReloadError(onErr))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	next := strings.Replace(testCSV, "Sony Corporation", "Sony Group", 1)
+	if _, err := Build(strings.NewReader(next), dir, nil); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if v, _ := db.Lookup("00:11:22"); v == "Sony Group" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("watch did not pick up the rebuilt dataset")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-reloadErrs: // a tick that overlapped the rebuild
+	default:
+	}
+
+	if err := os.Remove(filepath.Join(dir, defaultVendors)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Reload(); err == nil {
+		t.Fatal("want error reloading without a vendors file")
+	}
+	select {
+	case <-reloadErrs:
+	case <-time.After(2 * time.Second):
+		t.Error("failed watch reload was not reported")
+	}
+	if v, _ := db.Lookup("00:11:22"); v != "Sony Group" {
+		t.Errorf("failed reload replaced data: got %q", v)
+	}
+}
+
+// openCountingFS counts Opens and is no fs.StatFS, like an HTTPSource.
+type openCountingFS struct {
+	fsys  fs.FS
+	opens *atomic.Int32
+}
+
+func (c openCountingFS) Open(name string) (fs.File, error) {
+	c.opens.Add(1)
+	return c.fsys.Open(name)
+}
+
+func TestWatch_SkipsNonStatFS(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	var opens atomic.Int32
+	db, err := Open(WithFS(openCountingFS{os.DirFS(dir), &opens}), WithWatch(time.Millisecond))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	n := opens.Load()
+	time.Sleep(50 * time.Millisecond)
+	if got := opens.Load(); got != n {
+		t.Errorf("watch opened the files %d times", got-n)
+	}
+}
+
+func TestDB_OnChange(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	var got []VendorChange
+	db, err := Open(WithDir(dir), WithOnChange(func(c []VendorChange) { got = c }))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	next := strings.Replace(testCSV, "MA-L,001123,Acme GmbH,Addr 3\n", "MA-L,001126,Acme GmbH,Addr 3\n", 1)
+	next = strings.Replace(next, "Sony Corporation", "Sony Group", 1)
+	if _, err := Build(strings.NewReader(next), dir, nil); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	if err := db.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	want := []VendorChange{
+		{Prefix: "001122", Old: "Sony", New: "Sony Group"},
+		{Prefix: "001123", Old: "Acme"},
+		{Prefix: "001126", New: "Acme"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	oldDir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), oldDir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	prev, err := Open(WithDir(oldDir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if d := Diff(prev, db); !slices.Equal(d, want) {
+		t.Errorf("Diff = %v, want %v", d, want)
+	}
+	var kinds []string
+	for _, c := range want {
+		kinds = append(kinds, c.Kind())
+	}
+	if strings.Join(kinds, ",") != "renamed,removed,added" {
+		t.Errorf("kinds = %v", kinds)
+	}
+}
+
+func TestStoreDataset(t *testing.T) {
+	dir := t.TempDir()
+	build := func(csv string) string {
+		src := t.TempDir()
+		if _, err := Build(strings.NewReader(csv), src, nil); err != nil {
+			t.Fatalf("build: %v", err)
+		}
+		return src
+	}
+
+	first, err := StoreDataset(dir, build(testCSV))
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if again, err := StoreDataset(dir, build(testCSV)); err != nil || again != first {
+		t.Fatalf("identical content: got id %q (err %v), want %q", again, err, first)
+	}
+	second, err := StoreDataset(dir, build(strings.Replace(testCSV, "Sony", "Sony Group", 1)))
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+
+	lookup := func() string {
+		db, err := Open(WithDir(dir))
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		v, _ := db.Lookup("00:11:22")
+		return v
+	}
+	if v := lookup(); v != "Sony Group" {
+		t.Errorf("current dataset: got %q, want Sony Group", v)
+	}
+	if err := UseDataset(dir, first); err != nil {
+		t.Fatalf("roll back: %v", err)
+	}
+	if v := lookup(); v != "Sony" {
+		t.Errorf("after rollback: got %q, want Sony", v)
+	}
+
+	ids, cur, err := StoredDatasets(dir)
+	if err != nil || len(ids) != 2 || cur != first || !slices.Contains(ids, second) {
+		t.Errorf("StoredDatasets = %v, %q, %v", ids, cur, err)
+	}
+
+	// Building into a dir with a current link stores and switches to it.
+	if _, err := Build(strings.NewReader(strings.Replace(testCSV, "Sony", "Sony Corp", 1)), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultEntries)); !os.IsNotExist(err) {
+		t.Errorf("Build wrote flat files into %s", dir)
+	}
+	if v := lookup(); v != "Sony Corp" {
+		t.Errorf("after Build: got %q, want Sony Corp", v)
+	}
+}
+
+func TestLoad_Replacing(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, replacingName), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(WithDir(dir)); err == nil {
+		t.Errorf("Open while the files are being replaced: want an error")
+	}
+	os.Remove(filepath.Join(dir, replacingName))
+	if _, err := Open(WithDir(dir)); err != nil {
+		t.Errorf("open: %v", err)
+	}
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One"}) // legacy index without footer
+	writeEntries(t, dir, map[string]int{"abcdef": 0})
+
+	cfg := &openCfg{dir: dir, entriesName: defaultEntries, vendorsName: defaultVendors, indexName: defaultIndex}
+	if err := migrateLegacy(dir, cfg); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultEntries)); !os.IsNotExist(err) {
+		t.Errorf("legacy entries left in %s", dir)
+	}
+	idx, err := os.ReadFile(filepath.Join(dir, CurrentLink, defaultIndex))
+	if err != nil || !strings.HasSuffix(string(idx), indexMagic) {
+		t.Errorf("migrated index has no footer (err %v)", err)
+	}
+
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if v, _ := db.Lookup("ab:cd:ef"); v != "Vendor One" {
+		t.Errorf("lookup = %q", v)
+	}
+	if db.BuiltAt().IsZero() || db.Len() != 1 {
+		t.Errorf("missing dataset info: built %v, len %d", db.BuiltAt(), db.Len())
+	}
+}
@@ -0,0 +1,55 @@
+package pg_oui
+
+import "time"
+
+// DatasetInfoName is the file Build writes next to the dataset to record
+// where and when it was built.
+const DatasetInfoName = "dataset.json"
+
+// DatasetInfo is the content of DatasetInfoName.
+type DatasetInfo struct {
+	Version string    `json:"version"` // first 12 hex digits of the SHA-256 of the source data
+	BuiltAt time.Time `json:"built_at"`
+	Source  string    `json:"source,omitempty"`
+	Entries int       `json:"entries"`
+	Vendors int       `json:"vendors"`
+
+	Attribution Attribution `json:"attribution,omitzero"`
+}
+
+// Attribution credits the upstream data a dataset was built from, for
+// products that have to display its provenance.
+type Attribution struct {
+	Name        string    `json:"name"` // e.g. "IEEE Registration Authority (MA-L, MA-M, MA-S)"
+	URLs        []string  `json:"urls,omitempty"`
+	RetrievedAt time.Time `json:"retrieved_at,omitzero"`
+}
+
+// Metadata describes a loaded dataset.
+type Metadata struct {
+	// Source is where the dataset was loaded from: the winning Source name
+	// with WithFallback, otherwise "fs" (WithFS) or "dir".
+	Source  string
+	Entries int
+	Vendors int
+	// Attribution credits the upstream data, if the dataset records it.
+	Attribution Attribution
+}
+
+// Metadata returns information about the loaded dataset.
+func (db *DB) Metadata() Metadata {
+	d := db.cur.Load()
+	return Metadata{Source: d.source, Entries: d.entries.len(), Vendors: max(len(d.offsets)-1, 0), Attribution: d.info.Attribution}
+}
+
+// Version identifies the source data the dataset was built from, or returns
+// "" if the dataset has no DatasetInfoName file (e.g. built before it was
+// introduced).
+func (db *DB) Version() string { return db.cur.Load().info.Version }
+
+// BuiltAt returns when the dataset was built, or the zero time if unknown.
+// Compare it with the current time to alert on stale data.
+func (db *DB) BuiltAt() time.Time { return db.cur.Load().info.BuiltAt }
+
+// Len returns the number of prefixes in the dataset.
+func (db *DB) Len() int { return db.cur.Load().entries.len() }
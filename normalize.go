@@ -0,0 +1,134 @@
+package pg_oui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxInputLen is the longest input, in bytes, that Normalize and the Lookup
+// methods accept. Longer input is rejected without being scanned.
+const MaxInputLen = 256
+
+// NormalizeError explains why input was rejected. errors.Is(err,
+// ErrInvalidMAC) reports true for it.
+type NormalizeError struct {
+	Input  string // the rejected input, cut to 64 bytes
+	Reason string
+}
+
+func (e *NormalizeError) Error() string {
+	return fmt.Sprintf("invalid MAC address %q: %s", e.Input, e.Reason)
+}
+
+func (e *NormalizeError) Unwrap() error { return ErrInvalidMAC }
+
+func invalidMAC(s, format string, args ...any) error {
+	if len(s) > 64 {
+		s = s[:64]
+	}
+	return &NormalizeError{Input: s, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Normalize returns the lower-case hex digits of the MAC, EUI-64 or prefix
+// in s, the form Lookup matches on. It never panics; for any input it
+// either returns 6 to 16 hex digits or a *NormalizeError:
+//
+//   - surrounding white space, a leading 0x and the separators : - . and
+//     space (mixed freely) are removed, and a mac= parameter is taken from
+//     pasted URLs and key=value logs;
+//   - input longer than MaxInputLen, or containing control characters such
+//     as NUL or tab after that, is rejected;
+//   - the first 6 remaining characters must be hex digits (the OUI);
+//     hex digits after them are kept up to 16, and anything from the first
+//     non-hex character on (e.g. "xx" in a redacted MAC) is ignored.
+func Normalize(s string) (string, error) {
+	c, err := cleanMAC(s)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(c[:hexRun(c)]), nil
+}
+
+// NormalizeMAC returns the key a DB with default options looks s up by, so
+// callers can canonicalize input before keying their own caches on it: the
+// first 12 digits of Normalize (an EUI-64 is matched on its MAC part). Unlike
+// Normalize it rejects input with anything but hex digits and separators
+// left, such as "b8:27:eb:xx:xx:xx", or with more than 16 digits.
+func NormalizeMAC(s string) (prefix string, err error) {
+	c, err := cleanMAC(s)
+	if err != nil {
+		return "", err
+	}
+	if len(c) > 16 && allHex(c) {
+		return "", invalidMAC(s, "more than 16 hex digits")
+	}
+	if n := hexRun(c); n < len(c) {
+		return "", invalidMAC(s, "%q is not hex", c[n:])
+	}
+	return strings.ToLower(c[:min(len(c), 12)]), nil
+}
+
+// hexRun returns how many leading bytes of the cleanMAC output c Normalize
+// keeps: its hex digits up to the first other byte, at most 16.
+func hexRun(c string) int {
+	n := 6
+	for n < len(c) && n < 16 && isHex(c[n]) {
+		n++
+	}
+	return n
+}
+
+// cleanPrefix is the allocation-free path of Normalize for the common case
+// of input made only of hex digits and the separators : - . and space. It
+// returns the value of the first n (at most 12) hex digits and how many digits
+// there are in all; ok is false for any other input, which has to go through
+// Normalize.
+func cleanPrefix(s string) (v uint64, n, digits int, ok bool) {
+	if len(s) > MaxInputLen {
+		return 0, 0, 0, false
+	}
+	s = strings.TrimSpace(s)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c -= 'a' - 10
+		case 'A' <= c && c <= 'F':
+			c -= 'A' - 10
+		case c == ':' || c == '-' || c == '.' || c == ' ':
+			continue
+		default:
+			return 0, 0, 0, false
+		}
+		if n < 12 {
+			v = v<<4 | uint64(c)
+			n++
+		}
+		digits++
+	}
+	return v, n, digits, digits >= 6
+}
+
+// cleanMAC applies the checks and rewriting of Normalize, returning the
+// input without separators. At least its first 6 bytes are hex digits.
+func cleanMAC(s string) (string, error) {
+	if len(s) > MaxInputLen {
+		return "", invalidMAC(s, "longer than %d bytes", MaxInputLen)
+	}
+	c := extractMAC(strings.TrimSpace(s))
+	for i := 0; i < len(c); i++ {
+		if c[i] < 0x20 || c[i] == 0x7f {
+			return "", invalidMAC(s, "control character %q", c[i])
+		}
+	}
+	c = macCleaner.Replace(c)
+	if len(c) < 6 {
+		return "", invalidMAC(s, "fewer than 6 hex digits")
+	}
+	if !allHex(c[:6]) {
+		return "", invalidMAC(s, "OUI %q is not hex", c[:6])
+	}
+	return c, nil
+}
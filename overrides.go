@@ -0,0 +1,96 @@
+package pg_oui
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// OverridesName is the optional file of local vendor names read from the
+// data dir by Open: "prefix,name" CSV lines, where prefix is an OUI, a
+// longer prefix or a whole MAC in any form Normalize accepts. Lines starting
+// with # are comments.
+const OverridesName = "overrides.csv"
+
+// WithOverrides sets vendor names that take precedence over the dataset,
+// the special ranges and OverridesName, keyed by prefix as in OverridesName.
+// The longest matching prefix wins, so a whole MAC can name one device.
+func WithOverrides(m map[string]string) Option { return func(c *openCfg) { c.overrides = m } }
+
+// readOverrides returns the overrides of OverridesName in fsys and cfg,
+// keyed by overrideKey, or nil if there are none.
+func readOverrides(cfg *openCfg, fsys fs.FS) (map[uint64]string, error) {
+	m := make(map[uint64]string)
+	add := func(prefix, name string) error {
+		key, err := Normalize(prefix)
+		if err != nil {
+			return err
+		}
+		if len(key) > 12 {
+			return fmt.Errorf("prefix %q is longer than a MAC", prefix)
+		}
+		if name = strings.TrimSpace(name); name == "" {
+			return fmt.Errorf("empty name for %q", prefix)
+		}
+		v, n, _, _ := cleanPrefix(key)
+		m[overrideKey(v, n)] = name
+		return nil
+	}
+
+	f, err := fsys.Open(OverridesName)
+	switch {
+	case err == nil:
+		defer f.Close()
+		r := csv.NewReader(f)
+		r.Comment = '#'
+		r.FieldsPerRecord = 2
+		for {
+			rec, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err == nil {
+				line, _ := r.FieldPos(0)
+				if err = add(rec[0], rec[1]); err != nil {
+					err = fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w: %w", OverridesName, ErrCorruptDataset, err)
+			}
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("open %s: %w", OverridesName, err)
+	}
+
+	for prefix, name := range cfg.overrides {
+		if err := add(prefix, name); err != nil {
+			return nil, fmt.Errorf("override: %w", err)
+		}
+	}
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// overrideKey packs an n-digit prefix v into one map key.
+func overrideKey(v uint64, n int) uint64 { return v<<4 | uint64(n) }
+
+// override returns the name and length of the longest override prefix of the
+// n-digit prefix v (n <= 12).
+func (d *dataset) override(v uint64, n int) (string, int, bool) {
+	if d.overrides == nil {
+		return "", 0, false
+	}
+	for ; n >= 6; n-- {
+		if name, ok := d.overrides[overrideKey(v, n)]; ok {
+			return name, n, true
+		}
+		v >>= 4
+	}
+	return "", 0, false
+}
@@ -0,0 +1,211 @@
+// Package pcap resolves the vendors of the MAC addresses seen in packet
+// captures, replacing the usual tshark | sort -u | pg-oui pipeline. It reads
+// pcap and pcapng files with Ethernet or Linux cooked (SLL, SLL2) link
+// layers using only the standard library; frames of other link types are
+// skipped.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+// Link-layer header types, see https://www.tcpdump.org/linktypes.html.
+const (
+	linkEthernet = 1
+	linkSLL      = 113
+	linkSLL2     = 276
+)
+
+// maxBlock bounds the records and blocks FromPcap reads, so a corrupt
+// length cannot make it allocate gigabytes.
+const maxBlock = 16 << 20
+
+// Address is a unicast MAC address seen in a capture.
+type Address struct {
+	MAC      string `json:"mac"` // aa:bb:cc:dd:ee:ff
+	Vendor   string `json:"vendor"`
+	Sent     int    `json:"sent"`     // frames with MAC as source
+	Received int    `json:"received"` // frames with MAC as destination
+}
+
+// FromPcap reads a pcap or pcapng capture from r and returns every unicast
+// MAC address in it, sorted, with its vendor from db ("" if unknown) and
+// frame counts. Broadcast and multicast addresses are left out.
+func FromPcap(db *pg_oui.DB, r io.Reader) ([]Address, error) {
+	seen := map[[6]byte]*Address{}
+	add := func(mac []byte, src bool) {
+		if len(mac) != 6 || mac[0]&1 != 0 {
+			return
+		}
+		k := [6]byte(mac)
+		a := seen[k]
+		if a == nil {
+			a = &Address{MAC: net.HardwareAddr(mac).String()}
+			a.Vendor, _ = db.Lookup(a.MAC)
+			seen[k] = a
+		}
+		if src {
+			a.Sent++
+		} else {
+			a.Received++
+		}
+	}
+	frame := func(link int, b []byte) {
+		switch link {
+		case linkEthernet:
+			if len(b) >= 12 {
+				add(b[0:6], false)
+				add(b[6:12], true)
+			}
+		case linkSLL:
+			// Only the sender's address is recorded.
+			if len(b) >= 16 && b[4] == 0 && b[5] == 6 {
+				add(b[6:12], true)
+			}
+		case linkSLL2:
+			if len(b) >= 20 && b[11] == 6 {
+				add(b[12:18], true)
+			}
+		}
+	}
+
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("read capture header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(magic) == 0x0a0d0d0a {
+		err = readPcapng(br, frame)
+	} else {
+		err = readPcap(br, frame)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Address, 0, len(seen))
+	for _, a := range seen {
+		out = append(out, *a)
+	}
+	slices.SortFunc(out, func(a, b Address) int { return strings.Compare(a.MAC, b.MAC) })
+	return out, nil
+}
+
+// readPcap reads a classic pcap file, in either byte order and with micro-
+// or nanosecond timestamps.
+func readPcap(r io.Reader, frame func(link int, b []byte)) error {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return fmt.Errorf("read pcap header: %w", err)
+	}
+	var bo binary.ByteOrder
+	switch binary.LittleEndian.Uint32(hdr[:]) {
+	case 0xa1b2c3d4, 0xa1b23c4d:
+		bo = binary.LittleEndian
+	case 0xd4c3b2a1, 0x4d3cb2a1:
+		bo = binary.BigEndian
+	default:
+		return errors.New("not a pcap or pcapng file")
+	}
+	link := int(bo.Uint32(hdr[20:]) & 0xffff)
+	var rec [16]byte
+	var buf []byte
+	for n := 1; ; n++ {
+		if _, err := io.ReadFull(r, rec[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		caplen := bo.Uint32(rec[8:])
+		if caplen > maxBlock {
+			return fmt.Errorf("record %d: captured length %d too large", n, caplen)
+		}
+		buf = slices.Grow(buf[:0], int(caplen))[:caplen]
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		frame(link, buf)
+	}
+}
+
+// readPcapng reads a pcapng file: section headers set the byte order,
+// interface descriptions the link type of the packets that refer to them.
+func readPcapng(r io.Reader, frame func(link int, b []byte)) error {
+	var bo binary.ByteOrder = binary.LittleEndian
+	var links []int
+	var head [8]byte
+	var buf []byte
+	for n := 1; ; n++ {
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("block %d: %w", n, err)
+		}
+		typ := bo.Uint32(head[:])
+		if typ == 0x0a0d0d0a {
+			// A section header: its byte-order magic follows the length.
+			var magic [4]byte
+			if _, err := io.ReadFull(r, magic[:]); err != nil {
+				return fmt.Errorf("block %d: %w", n, err)
+			}
+			switch binary.LittleEndian.Uint32(magic[:]) {
+			case 0x1a2b3c4d:
+				bo = binary.LittleEndian
+			case 0x4d3c2b1a:
+				bo = binary.BigEndian
+			default:
+				return fmt.Errorf("block %d: bad byte-order magic", n)
+			}
+			links = links[:0]
+			buf = append(append(buf[:0], head[:]...), magic[:]...)
+		} else {
+			buf = append(buf[:0], head[:]...)
+		}
+		size := bo.Uint32(head[4:])
+		if size < 12 || size%4 != 0 || size > maxBlock || int(size) < len(buf) {
+			return fmt.Errorf("block %d: bad length %d", n, size)
+		}
+		start := len(buf)
+		buf = slices.Grow(buf, int(size)-start)[:size]
+		if _, err := io.ReadFull(r, buf[start:]); err != nil {
+			return fmt.Errorf("block %d: %w", n, err)
+		}
+		body := buf[8 : size-4]
+
+		switch typ {
+		case 1: // interface description
+			if len(body) >= 2 {
+				links = append(links, int(bo.Uint16(body)))
+			}
+		case 6, 2: // enhanced packet, obsolete packet
+			if len(body) < 20 {
+				continue
+			}
+			var iface int
+			if typ == 6 {
+				iface = int(bo.Uint32(body))
+			} else {
+				iface = int(bo.Uint16(body))
+			}
+			caplen := bo.Uint32(body[12:])
+			if iface < len(links) && uint64(caplen) <= uint64(len(body)-20) {
+				frame(links[iface], body[20:20+caplen])
+			}
+		case 3: // simple packet, always on the first interface
+			if len(body) >= 4 && len(links) > 0 {
+				frame(links[0], body[4:min(len(body), 4+int(bo.Uint32(body)))])
+			}
+		}
+	}
+}
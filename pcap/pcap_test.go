@@ -0,0 +1,115 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	pg_oui "github.com/pre-history/pg-oui"
+)
+
+const testCSV = `Registry,Assignment,Organization Name,Organization Address
+MA-L,B827EB,Raspberry Pi Foundation,Addr
+MA-L,00000C,"Cisco Systems, Inc",Addr
+`
+
+var (
+	pi        = []byte{0xb8, 0x27, 0xeb, 1, 2, 3}
+	cisco     = []byte{0x00, 0x00, 0x0c, 4, 5, 6}
+	broadcast = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+// ethernet returns a minimal Ethernet frame from src to dst.
+func ethernet(dst, src []byte) []byte {
+	return append(append(append([]byte{}, dst...), src...), 0x08, 0x00)
+}
+
+func pcapFile(bo binary.ByteOrder, frames ...[]byte) []byte {
+	var b bytes.Buffer
+	hdr := make([]byte, 24)
+	bo.PutUint32(hdr, 0xa1b2c3d4)
+	bo.PutUint32(hdr[16:], 65535)
+	bo.PutUint32(hdr[20:], linkEthernet)
+	b.Write(hdr)
+	for _, f := range frames {
+		rec := make([]byte, 16)
+		bo.PutUint32(rec[8:], uint32(len(f)))
+		bo.PutUint32(rec[12:], uint32(len(f)))
+		b.Write(rec)
+		b.Write(f)
+	}
+	return b.Bytes()
+}
+
+func pcapngFile(frames ...[]byte) []byte {
+	le := binary.LittleEndian
+	var b bytes.Buffer
+	block := func(typ uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		n := uint32(len(body) + 12)
+		b.Write(le.AppendUint32(le.AppendUint32(nil, typ), n))
+		b.Write(body)
+		b.Write(le.AppendUint32(nil, n))
+	}
+	block(0x0a0d0d0a, le.AppendUint64(le.AppendUint32(le.AppendUint32(nil, 0x1a2b3c4d), 1), ^uint64(0)))
+	block(1, le.AppendUint32(le.AppendUint32(nil, linkEthernet), 65535))
+	for _, f := range frames {
+		body := le.AppendUint32(nil, 0)
+		body = le.AppendUint64(body, 0)
+		body = le.AppendUint32(le.AppendUint32(body, uint32(len(f))), uint32(len(f)))
+		block(6, append(body, f...))
+	}
+	return b.Bytes()
+}
+
+func TestFromPcap(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := pg_oui.Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := pg_oui.Open(pg_oui.WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	frames := [][]byte{ethernet(cisco, pi), ethernet(pi, cisco), ethernet(broadcast, pi)}
+	want := []Address{
+		{MAC: "00:00:0c:04:05:06", Vendor: "Cisco Systems", Sent: 1, Received: 1},
+		{MAC: "b8:27:eb:01:02:03", Vendor: "Raspberry Pi Foundation", Sent: 2, Received: 1},
+	}
+	for name, file := range map[string][]byte{
+		"pcap-le": pcapFile(binary.LittleEndian, frames...),
+		"pcap-be": pcapFile(binary.BigEndian, frames...),
+		"pcapng":  pcapngFile(frames...),
+	} {
+		got, err := FromPcap(db, bytes.NewReader(file))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+	if _, err := FromPcap(db, strings.NewReader("not a capture file at all")); err == nil {
+		t.Error("garbage input: want error")
+	}
+}
+
+func FuzzFromPcap(f *testing.F) {
+	dir := f.TempDir()
+	if _, err := pg_oui.Build(strings.NewReader(testCSV), dir, nil); err != nil {
+		f.Fatalf("build: %v", err)
+	}
+	db, err := pg_oui.Open(pg_oui.WithDir(dir))
+	if err != nil {
+		f.Fatalf("open: %v", err)
+	}
+	f.Add(pcapFile(binary.LittleEndian, ethernet(cisco, pi)))
+	f.Add(pcapngFile(ethernet(pi, cisco)))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = FromPcap(db, bytes.NewReader(b))
+	})
+}
@@ -0,0 +1,77 @@
+package pg_oui
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadPopularity reads a vendor popularity file for WithPopularity:
+// "vendor,weight" CSV lines such as "Apple,100", where weight is an
+// integer. Lines starting with # are comments.
+func ReadPopularity(r io.Reader) (map[string]int, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	m := make(map[string]int)
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		name := strings.TrimSpace(rec[0])
+		if name == "" {
+			return nil, fmt.Errorf("line %d: empty vendor name", line)
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(rec[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad weight: %w", line, err)
+		}
+		m[name] = w
+	}
+}
+
+// WithPopularity makes SearchVendors rank matches of the same kind by
+// weight, highest first, as read by ReadPopularity. Vendors are matched
+// like alias variants (see WithAliases); unlisted vendors weigh 0.
+func WithPopularity(m map[string]int) Option { return func(c *openCfg) { c.popularity = m } }
+
+// WithRankByPrefixes makes SearchVendors rank matches of the same kind by
+// their number of allocated prefixes, most first, after any WithPopularity
+// weight.
+func WithRankByPrefixes(v bool) Option { return func(c *openCfg) { c.rankByPrefixes = v } }
+
+// newPopularityTable keys the weights of m by aliasKey.
+func newPopularityTable(m map[string]int) map[string]int {
+	if len(m) == 0 {
+		return nil
+	}
+	t := make(map[string]int, len(m))
+	for name, w := range m {
+		t[aliasKey(name)] = w
+	}
+	return t
+}
+
+// prefixCounts returns the number of prefixes of each vendor name.
+func (d *dataset) prefixCounts() map[string]int {
+	byID := make(map[int]int)
+	for _, id := range d.entries.all() {
+		byID[id]++
+	}
+	n := make(map[string]int, len(byID))
+	for id, c := range byID {
+		if v, err := d.vendorByID(id); err == nil {
+			n[v] += c
+		}
+	}
+	return n
+}
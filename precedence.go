@@ -0,0 +1,91 @@
+package pg_oui
+
+import "fmt"
+
+// Layer is a source of lookup answers, reported in Record.Source.
+type Layer string
+
+const (
+	LayerOverride Layer = "override" // WithOverrides and OverridesName
+	LayerSpecial  Layer = "special"  // the built-in table, see WithSpecialRanges
+	LayerRegistry Layer = "registry" // the dataset's registry assignments
+)
+
+// WithPrecedence makes lookups answer from the first of layers that knows
+// the address, whatever the length of its prefix; layers left out are not
+// consulted. By default overrides come first, then whichever of the special
+// ranges and the registries has the longer prefix, the registries winning
+// ties.
+func WithPrecedence(layers ...Layer) Option { return func(c *openCfg) { c.precedence = layers } }
+
+func checkPrecedence(layers []Layer) error {
+	for _, l := range layers {
+		switch l {
+		case LayerOverride, LayerSpecial, LayerRegistry:
+		default:
+			return fmt.Errorf("unknown precedence layer %q", l)
+		}
+	}
+	return nil
+}
+
+// answer is where resolve found an address.
+type answer struct {
+	layer  Layer
+	name   string // the answer of LayerOverride and LayerSpecial
+	id     int    // the vendor ID of LayerRegistry
+	digits int    // length of the matched prefix in hex digits
+	bits   int    // length of the matched prefix in bits
+}
+
+// resolve finds the n-digit prefix v of a MAC (n <= 12) in the DB's layers.
+func (d *dataset) resolve(v uint64, n int) (answer, bool) {
+	if d.precedence != nil {
+		for _, l := range d.precedence {
+			if a, ok := d.resolveIn(l, v, n, 0); ok {
+				return a, true
+			}
+		}
+		return answer{}, false
+	}
+	if a, ok := d.resolveIn(LayerOverride, v, n, 0); ok {
+		return a, true
+	}
+	a, ok := d.resolveIn(LayerRegistry, v, n, 0)
+	if s, special := d.resolveIn(LayerSpecial, v, n, 4*a.digits); special {
+		return s, true
+	}
+	return a, ok
+}
+
+// resolveIn looks v up in one layer. Special ranges must be longer than
+// minBits.
+func (d *dataset) resolveIn(l Layer, v uint64, n, minBits int) (answer, bool) {
+	switch l {
+	case LayerOverride:
+		name, m, ok := d.override(v, n)
+		return answer{layer: l, name: name, digits: m, bits: 4 * m}, ok
+	case LayerSpecial:
+		if d.noSpecial {
+			break
+		}
+		r, ok := matchSpecial(v, n, minBits)
+		return answer{layer: l, name: r.label, digits: (r.bits + 3) / 4, bits: r.bits}, ok
+	case LayerRegistry:
+		if n > 9 {
+			v, n = v>>(4*(n-9)), 9
+		}
+		m, id, ok := d.entries.matchValue(v, n)
+		return answer{layer: l, id: id, digits: m, bits: 4 * m}, ok
+	}
+	return answer{}, false
+}
+
+// resolveKey is resolve for a normalized key.
+func (d *dataset) resolveKey(key string) (answer, bool) {
+	v, n, _, ok := cleanPrefix(key)
+	if !ok {
+		return answer{}, false
+	}
+	return d.resolve(v, n)
+}
@@ -0,0 +1,94 @@
+package pg_oui
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Record is the registry entry behind a lookup.
+type Record struct {
+	Prefix string // matched prefix: 6, 7 or 9 lower-case hex digits, or that of a special range or override
+	Vendor string // simplified name, as returned by Lookup
+	// ShortName is a Wireshark-style name of at most 8 characters, e.g.
+	// "Raspberr" or "Cisco", for narrow columns.
+	ShortName string
+	Registry  string // MA-L, MA-M, MA-S or CID
+	Source    Layer  // which layer answered, see WithPrecedence
+	// Bits is the length of the matched prefix: 24, 28 or 36 for MA-L,
+	// MA-M and MA-S blocks, that of the special range or up to 48 for an
+	// override.
+	Bits       int
+	Confidence Confidence
+	Truncated  bool   // part of the input was ignored, see WithTruncate
+	RawName    string // organization name as registered
+	Address    string // organization address as registered
+}
+
+// Confidence tells how specific a Record's match is, for resolving
+// conflicts with other sources.
+type Confidence string
+
+const (
+	// ConfidenceHigh: no assignment in the dataset is more specific than the
+	// match, or it is an override or special range.
+	ConfidenceHigh Confidence = "high"
+	// ConfidenceLow: only a block that is split into longer assignments
+	// matched, e.g. an MA-L block resold in MA-S chunks, none of which
+	// contains the address (or the input is too short to tell).
+	ConfidenceLow Confidence = "low"
+)
+
+// LookupRecord is like LookupErr but returns the full registry entry.
+// Registry, RawName and Address are only known for datasets built with the
+// v2 entries format and are empty otherwise; ShortName is then derived from
+// Vendor.
+func (db *DB) LookupRecord(s string) (Record, error) {
+	d := db.cur.Load()
+	key, truncated, err := d.normalize(s)
+	if err != nil {
+		return Record{}, err
+	}
+	a, ok := d.resolveKey(key)
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	p := key[:a.digits]
+	if a.layer != LayerRegistry {
+		return Record{Prefix: p, Vendor: a.name, ShortName: ShortName(a.name), Source: a.layer, Bits: a.bits, Confidence: ConfidenceHigh, Truncated: truncated}, nil
+	}
+	v, err := d.vendorOf(a.id)
+	if err != nil {
+		return Record{}, err
+	}
+	rec := Record{Prefix: p, Vendor: v, ShortName: ShortName(v), Source: LayerRegistry, Bits: a.bits, Confidence: ConfidenceHigh, Truncated: truncated}
+	if pv, ok := parseKey(p); ok && d.entries.split(pv, len(p)) {
+		rec.Confidence = ConfidenceLow
+	}
+	if extra := d.records[p]; len(extra) >= 3 {
+		rec.Registry, rec.RawName, rec.Address = extra[0], extra[1], extra[2]
+		if len(extra) >= 4 && extra[3] != "" {
+			rec.ShortName = extra[3]
+		}
+	}
+	return rec, nil
+}
+
+// shortTerms are dropped from names by ShortName.
+var shortTerms = regexp.MustCompile(`(?i)\b(the|inc|incorporated|corp|corporation|co|company|ltd|limited|llc|gmbh|ag|ab|plc|pty|sa|srl|spa|bv|nv|kg|oy|systems|technology|technologies|international|holdings?)\b`)
+
+// ShortName derives a Wireshark-style short vendor name from name: business
+// terms and punctuation removed, words joined, cut to 8 characters.
+func ShortName(name string) string {
+	s := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, name)
+	if plain := shortTerms.ReplaceAllString(s, ""); strings.TrimSpace(plain) != "" {
+		s = plain
+	}
+	r := []rune(strings.Join(strings.Fields(s), ""))
+	return string(r[:min(len(r), 8)])
+}
@@ -0,0 +1,129 @@
+package pg_oui
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WithWatch makes the DB poll the data files every interval and Reload
+// when their size or modification time changes, until Close is called. It
+// polls rather than subscribing to file system events, which keeps the
+// package free of dependencies and works on network mounts. Datasets read
+// from an fs.FS that is no fs.StatFS, e.g. an HTTPSource, are not watched,
+// as every check would download the files. Failed reloads, e.g. of a
+// half-written update, keep the current data, are reported to the
+// WithOnReloadError func and are retried on the next tick.
+func WithWatch(interval time.Duration) Option { return func(c *openCfg) { c.watch = interval } }
+
+// WithOnReloadError calls fn with the error of each failed WithWatch reload.
+// fn runs synchronously in the watching goroutine.
+func WithOnReloadError(fn func(error)) Option { return func(c *openCfg) { c.onReloadError = fn } }
+
+// VendorChange is a prefix whose vendor differs between two datasets. Old is
+// empty for added prefixes and New for removed ones.
+type VendorChange struct {
+	Prefix string `json:"prefix"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// Kind reports whether the prefix was "added", "removed" or "renamed"
+// (assigned to another vendor).
+func (c VendorChange) Kind() string {
+	switch {
+	case c.Old == "":
+		return "added"
+	case c.New == "":
+		return "removed"
+	}
+	return "renamed"
+}
+
+// Diff lists the prefixes whose vendor differs between the datasets of from
+// and to, sorted by prefix, as WithOnChange reports them.
+func Diff(from, to *DB) []VendorChange { return diffDatasets(from.cur.Load(), to.cur.Load()) }
+
+// WithOnChange calls fn after a Reload (including WithWatch reloads) that
+// added, removed or reassigned prefixes, with the changes sorted by prefix.
+// fn runs synchronously in the reloading goroutine.
+func WithOnChange(fn func([]VendorChange)) Option { return func(c *openCfg) { c.onChange = fn } }
+
+// Reload loads the dataset again from where Open found it and swaps it in
+// atomically: concurrent lookups see either the old or the new data, never a
+// mix. On error the current data stays in use.
+func (db *DB) Reload() error { return db.reload(context.Background()) }
+
+func (db *DB) reload(ctx context.Context) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	d, err := db.open(ctx)
+	if err != nil {
+		return err
+	}
+	old := db.cur.Swap(d)
+	if old != nil && db.onChange != nil {
+		if changes := diffDatasets(old, d); len(changes) > 0 {
+			db.onChange(changes)
+		}
+	}
+	return nil
+}
+
+// diffDatasets lists the prefixes whose vendor differs between a and b.
+func diffDatasets(a, b *dataset) []VendorChange {
+	var changes []VendorChange
+	for p := range a.entries.all() {
+		old, _ := a.lookupKey(p)
+		if v, _ := b.lookupKey(p); v != old {
+			changes = append(changes, VendorChange{Prefix: p, Old: old, New: v})
+		}
+	}
+	for p := range b.entries.all() {
+		if _, ok := a.entries.get(p); !ok {
+			v, _ := b.lookupKey(p)
+			changes = append(changes, VendorChange{Prefix: p, New: v})
+		}
+	}
+	slices.SortFunc(changes, func(x, y VendorChange) int { return strings.Compare(x.Prefix, y.Prefix) })
+	return changes
+}
+
+// Close stops WithWatch polling. Lookups keep working after Close.
+func (db *DB) Close() error {
+	db.once.Do(func() { close(db.stop) })
+	return nil
+}
+
+func (db *DB) watch(cfg *openCfg, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-t.C:
+		}
+		d := db.cur.Load()
+		if _, ok := d.fsys.(fs.StatFS); !ok || fileStamp(d.fsys, cfg) == d.stamp {
+			continue
+		}
+		if err := db.Reload(); err != nil && cfg.onReloadError != nil {
+			cfg.onReloadError(err)
+		}
+	}
+}
+
+// fileStamp summarizes the size and modification time of the data files.
+func fileStamp(fsys fs.FS, cfg *openCfg) string {
+	var b strings.Builder
+	for _, n := range []string{DatasetInfoName, BinaryName, cfg.entriesName, cfg.vendorsName, cfg.indexName, OverridesName} {
+		if st, err := fs.Stat(fsys, n); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", n, st.Size(), st.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package pg_oui
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// OUIsForVendor returns the sorted prefixes (6, 7 or 9 hex digits) registered
+// to the vendor with the given name, compared case-insensitively. It returns
+// ErrNotFound if no vendor has that name. Each call scans the whole dataset.
+func (db *DB) OUIsForVendor(name string) ([]string, error) {
+	name = strings.TrimSpace(name)
+	return db.cur.Load().ouisWhere(func(v string) bool { return strings.EqualFold(v, name) })
+}
+
+// OUIsForVendorFuzzy is like OUIsForVendor but matches every vendor whose
+// name contains query, ignoring case, punctuation and spaces, so "cisco"
+// covers "Cisco Systems" and "Cisco-Linksys".
+func (db *DB) OUIsForVendorFuzzy(query string) ([]string, error) {
+	q := fold(query)
+	if q == "" {
+		return nil, ErrNotFound
+	}
+	return db.cur.Load().ouisWhere(func(v string) bool { return strings.Contains(fold(v), q) })
+}
+
+// OUIsForVendorRegexp is like OUIsForVendor but matches every vendor whose
+// name matches re.
+func (db *DB) OUIsForVendorRegexp(re *regexp.Regexp) ([]string, error) {
+	return db.cur.Load().ouisWhere(re.MatchString)
+}
+
+func (d *dataset) ouisWhere(match func(vendor string) bool) ([]string, error) {
+	ids := make(map[int]bool)
+	for id := 0; id+1 < len(d.offsets); id++ {
+		if v, err := d.vendorByID(id); err == nil && match(v) {
+			ids[id] = true
+		}
+	}
+	var out []string
+	for oui, id := range d.entries.all() {
+		if ids[id] {
+			out = append(out, oui)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+// fold lower-cases s and drops everything but letters and digits.
+func fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
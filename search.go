@@ -1,4 +1,110 @@
 package pg_oui
 
-// Backward-compatibility wrappers are now implemented in db.go.
-// This file is kept to preserve package structure.
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// SearchVendors returns up to limit vendor names matching query, best first,
+// for autocomplete. Like OUIsForVendorFuzzy it ignores case, punctuation and
+// spaces. Names equal to the query rank first, then names starting with it,
+// then names containing it (earlier is better), then names containing it
+// with a typo: one edit per four characters of query. Within each of these
+// kinds, WithPopularity and WithRankByPrefixes put the more popular vendors
+// first. limit <= 0 returns every match.
+func (db *DB) SearchVendors(query string, limit int) []string {
+	q := fold(query)
+	if q == "" {
+		return nil
+	}
+	d := db.cur.Load()
+	type match struct {
+		name       string
+		tier, rank int
+		weight     int
+		count      int
+	}
+	var counts map[string]int
+	if db.byCount {
+		counts = d.prefixCounts()
+	}
+	var matches []match
+	seen := make(map[string]bool)
+	for id := 0; id+1 < len(d.offsets); id++ {
+		v, err := d.vendorByID(id)
+		if err != nil || v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		f := fold(v)
+		m := match{name: v, tier: -1}
+		switch i := strings.Index(f, q); {
+		case f == q:
+			m.tier = 0
+		case i == 0:
+			m.tier = 1
+		case i > 0:
+			m.tier, m.rank = 2, i
+		case len(q) >= 4:
+			if dist := substringDistance(q, f); dist <= len(q)/4 {
+				m.tier, m.rank = 3, dist
+			}
+		}
+		if m.tier < 0 {
+			continue
+		}
+		if db.weights != nil {
+			m.weight = db.weights[aliasKey(v)]
+		}
+		m.count = counts[v]
+		matches = append(matches, m)
+	}
+	slices.SortFunc(matches, func(a, b match) int {
+		if a.tier != b.tier {
+			return a.tier - b.tier
+		}
+		if a.weight != b.weight {
+			return cmp.Compare(b.weight, a.weight)
+		}
+		if a.count != b.count {
+			return b.count - a.count
+		}
+		if a.rank != b.rank {
+			return a.rank - b.rank
+		}
+		if len(a.name) != len(b.name) {
+			return len(a.name) - len(b.name)
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	out := make([]string, len(matches))
+	for i, m := range matches {
+		out[i] = m.name
+	}
+	return out
+}
+
+// substringDistance returns the smallest Levenshtein distance between q and
+// any substring of s.
+func substringDistance(q, s string) int {
+	// prev[j] is the distance between q[:i] and the best substring of s
+	// ending at j; a match may start anywhere, so row 0 is all zeros.
+	prev := make([]int, len(s)+1)
+	cur := make([]int, len(s)+1)
+	for i := 1; i <= len(q); i++ {
+		cur[0] = i
+		for j := 1; j <= len(s); j++ {
+			cost := 1
+			if q[i-1] == s[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j-1]+cost, prev[j]+1, cur[j-1]+1)
+		}
+		prev, cur = cur, prev
+	}
+	return slices.Min(prev)
+}
@@ -3,8 +3,12 @@ package pg_oui
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -126,6 +130,52 @@ func TestSearchVendor_NormalizationAndTrim(t *testing.T) {
 	}
 }
 
+func TestSearchVendors(t *testing.T) {
+	dir := t.TempDir()
+	csv := testCSV + "MA-L,001126,Ubiquiti Inc,Addr\nMA-L,001127,Ubiquiti Networks Inc,Addr\nMA-L,001128,Acme Ubiq,Addr\n"
+	if _, err := Build(strings.NewReader(csv), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	db, err := Open(WithDir(dir))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got := db.SearchVendors("ubiq", 0); strings.Join(got, "|") != "Ubiquiti|Ubiquiti Networks|Acme Ubiq" {
+		t.Errorf("substring: got %q", got)
+	}
+	if got := db.SearchVendors("ubiqiti", 1); strings.Join(got, "|") != "Ubiquiti" {
+		t.Errorf("typo: got %q", got)
+	}
+	if got := db.SearchVendors("zzzz", 10); len(got) != 0 {
+		t.Errorf("no match: got %q", got)
+	}
+
+	// Ubiquiti Networks gets a second prefix.
+	csv += "MA-L,001129,Ubiquiti Networks Inc,Addr\n"
+	if _, err := Build(strings.NewReader(csv), dir, nil); err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if db, err = Open(WithDir(dir), WithRankByPrefixes(true)); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got := db.SearchVendors("ubiq", 0); strings.Join(got, "|") != "Ubiquiti Networks|Ubiquiti|Acme Ubiq" {
+		t.Errorf("by prefixes: got %q", got)
+	}
+	pop, err := ReadPopularity(strings.NewReader("# weights\nUBIQUITI INC,5\nAcme Ubiq,9\n"))
+	if err != nil {
+		t.Fatalf("read popularity: %v", err)
+	}
+	if db, err = Open(WithDir(dir), WithPopularity(pop), WithRankByPrefixes(true)); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got := db.SearchVendors("ubiq", 0); strings.Join(got, "|") != "Ubiquiti|Ubiquiti Networks|Acme Ubiq" {
+		t.Errorf("popularity: got %q", got)
+	}
+	if _, err := ReadPopularity(strings.NewReader("Acme,many\n")); err == nil {
+		t.Error("want error for a bad weight")
+	}
+}
+
 func TestOUIIndexing(t *testing.T) {
 	dir := t.TempDir()
 
@@ -209,3 +259,492 @@ func TestOUIIndexingRealData(t *testing.T) {
 		}
 	}
 }
+
+func TestLookupErr_StrictInput(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One"})
+	writeEntries(t, dir, map[string]int{"abcdef": 0})
+
+	lenient, err := Open(WithDir(dir), WithAutoUpdate(false), WithTruncate(true))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	strict, err := Open(WithDir(dir), WithAutoUpdate(false), WithStrictInput(true))
+	if err != nil {
+		t.Fatalf("open strict: %v", err)
+	}
+
+	testCases := []struct {
+		in         string
+		lenientErr error
+		strictErr  error
+	}{
+		{"ab:cd:ef:01:02:03", nil, nil},
+		{"abcdef", nil, nil},
+		{"ab-cd-ef-01-02-03-04-05", nil, nil},
+		{"abcdef0", nil, ErrInvalidMAC},           // wrong length
+		{"ab:cd:ef:zz:zz:zz", nil, ErrInvalidMAC}, // non-hex after the OUI
+		{"zz-zz-zz", ErrInvalidMAC, ErrInvalidMAC},
+		{"abc", ErrInvalidMAC, ErrInvalidMAC},
+		{"12:34:56", ErrNotFound, ErrNotFound},
+	}
+	for _, tc := range testCases {
+		if _, err := lenient.LookupErr(tc.in); !errors.Is(err, tc.lenientErr) {
+			t.Errorf("lenient %q: got err %v, want %v", tc.in, err, tc.lenientErr)
+		}
+		if _, err := strict.LookupErr(tc.in); !errors.Is(err, tc.strictErr) {
+			t.Errorf("strict %q: got err %v, want %v", tc.in, err, tc.strictErr)
+		}
+		if _, ok := strict.Lookup(tc.in); ok != (tc.strictErr == nil) {
+			t.Errorf("strict Lookup %q: got ok=%v", tc.in, ok)
+		}
+	}
+}
+
+func TestLookupStrict(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One", "Vendor Two"})
+	writeEntries(t, dir, map[string]int{"001122": 0, "021122": 1})
+	db, err := Open(WithDir(dir), WithAutoUpdate(false), WithTruncate(true))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	testCases := []struct {
+		in, want string
+		err      error
+	}{
+		{"00:11:22:33:44:55", "Vendor One", nil},
+		{"001122", "Vendor One", nil},
+		{"00:11:22:33:44:55:66:77", "Vendor One", nil},
+		{"00:11:22:33", "", ErrInvalidMAC},
+		{"00:11:22:zz:zz:zz", "", ErrInvalidMAC},
+		{"zz:zz:zz:zz:zz:zz", "", ErrInvalidMAC},
+		{"02:11:22:33:44:55", "", ErrLocallyAdministered},
+		{"02:42:ac:11:00:02", "Docker container", nil},
+		{"00:11:23:00:00:00", "", ErrNotFound},
+	}
+	for _, tc := range testCases {
+		got, err := db.LookupStrict(tc.in)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("LookupStrict(%q) = %q, %v, want %q, %v", tc.in, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestWithTruncate(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One"})
+	writeEntries(t, dir, map[string]int{"abcdef": 0})
+
+	db, err := Open(WithDir(dir), WithAutoUpdate(false))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	truncating, err := Open(WithDir(dir), WithAutoUpdate(false), WithTruncate(true))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, in := range []string{"ab:cd:ef:xx:xx:xx", "abcdef01020304050607", "ab:cd:ef:01:02:03 extra"} {
+		if _, err := db.LookupErr(in); !errors.Is(err, ErrInvalidMAC) {
+			t.Errorf("%q: got err %v, want ErrInvalidMAC", in, err)
+		}
+		rec, err := truncating.LookupRecord(in)
+		if err != nil || rec.Vendor != "Vendor One" || !rec.Truncated {
+			t.Errorf("%q with WithTruncate: got %+v, %v; want a truncated Vendor One", in, rec, err)
+		}
+	}
+	for _, in := range []string{"abcdef", "ab:cd:ef:01:02:03", "abcdef0102030405", "abcdef0"} {
+		if rec, err := db.LookupRecord(in); err != nil || rec.Truncated {
+			t.Errorf("%q: got %+v, %v; want an untruncated match", in, rec, err)
+		}
+	}
+}
+
+func TestLookup_PrefixedAndEmbeddedInput(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One"})
+	writeEntries(t, dir, map[string]int{"abcdef": 0})
+
+	db, err := Open(WithDir(dir), WithAutoUpdate(false), WithStrictInput(true))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	for _, in := range []string{
+		"0xABCDEF010203",
+		"0Xabcdef",
+		"mac=AB-CD-EF-01-02-03",
+		"MAC=ab%3Acd%3Aef%3A01%3A02%3A03",
+		"/api/devices?id=7&mac=ab:cd:ef:01:02:03&vlan=10",
+		"src=abcdef010203",
+	} {
+		if got, err := db.LookupErr(in); err != nil || got != "Vendor One" {
+			t.Errorf("%q: got %q, err=%v; want 'Vendor One'", in, got, err)
+		}
+	}
+
+	if _, err := db.LookupErr("id=7&vlan=10"); !errors.Is(err, ErrInvalidMAC) {
+		t.Errorf("want ErrInvalidMAC for query without a MAC, got %v", err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		in, want string
+	}{
+		{"AA:BB:CC:DD:EE:FF", "aabbccddeeff"},
+		{" aa-bb.cc dd:ee-ff\n", "aabbccddeeff"},
+		{"0xAABBCC", "aabbcc"},
+		{"b8:27:eb:xx:xx:xx", "b827eb"},
+		{"aabbccddeeff0011", "aabbccddeeff0011"},
+		{"aabbccddeeff00112233", "aabbccddeeff0011"},
+		{"aabbcc\x00dd", ""},
+		{"aa\tbbcc", ""},
+		{"aabbc", ""},
+		{"aabbcg", ""},
+		{strings.Repeat("a", MaxInputLen+1), ""},
+		{"mac=aa%3Abb%3Acc%00", ""},
+	}
+	for _, tc := range testCases {
+		got, err := Normalize(tc.in)
+		if got != tc.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+		var nerr *NormalizeError
+		if tc.want == "" && (!errors.As(err, &nerr) || !errors.Is(err, ErrInvalidMAC)) {
+			t.Errorf("Normalize(%q): want *NormalizeError matching ErrInvalidMAC, got %v", tc.in, err)
+		}
+	}
+}
+
+func TestNormalizeMAC(t *testing.T) {
+	testCases := []struct {
+		in, want string
+	}{
+		{"AA:BB:CC:DD:EE:FF", "aabbccddeeff"},
+		{"0xAABBCC", "aabbcc"},
+		{"b8:27:eb:3", "b827eb3"},
+		{"aabbccddeeff0011", "aabbccddeeff"},
+		{"b8:27:eb:xx:xx:xx", ""},
+		{"aa:bb:cc:dd:ee:ff junk", ""},
+		{"aabbccddeeff00112233", ""},
+		{"zz:zz:zz:zz:zz:zz", ""},
+	}
+	for _, tc := range testCases {
+		got, err := NormalizeMAC(tc.in)
+		if got != tc.want || (tc.want == "") != errors.Is(err, ErrInvalidMAC) {
+			t.Errorf("NormalizeMAC(%q) = %q, %v, want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func FuzzNormalize(f *testing.F) {
+	for _, s := range []string{"aa:bb:cc:dd:ee:ff", "0x", "mac=%zz", "?mac=&", "aabbcc\x00", "\xff\xfe:aa", "=", "AABBCC-DDEEFF-0011"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		got, err := Normalize(s)
+		if v, n, _, ok := cleanPrefix(s); ok {
+			if err != nil || formatKey(v, n) != got[:min(len(got), 12)] {
+				t.Fatalf("cleanPrefix(%q) = %x (%d digits), Normalize = %q, %v", s, v, n, got, err)
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidMAC) {
+				t.Fatalf("Normalize(%q): error %v does not match ErrInvalidMAC", s, err)
+			}
+			return
+		}
+		if len(got) < 6 || len(got) > 16 || !allHex(got) || strings.ToLower(got) != got {
+			t.Fatalf("Normalize(%q) = %q, want 6 to 16 lower-case hex digits", s, got)
+		}
+	})
+}
+
+func TestLookupFromHardwareAddr_Lengths(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One"})
+	writeEntries(t, dir, map[string]int{"abcdef": 0})
+
+	db, err := Open(WithDir(dir), WithAutoUpdate(false))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		hw   string
+		err  error
+	}{
+		{"mac48", "ab:cd:ef:01:02:03", nil},
+		{"eui64", "ab:cd:ef:ff:fe:01:02:03", nil},
+		{"infiniband", "80:00:04:04:fe:80:00:00:00:00:00:00:ab:cd:ef:03:00:01:02:03", nil},
+		{"infiniband unknown guid", "ab:cd:ef:04:fe:80:00:00:00:00:00:00:00:11:22:03:00:01:02:03", ErrNotFound},
+	}
+	for _, tc := range testCases {
+		hw, err := net.ParseMAC(tc.hw)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", tc.name, err)
+		}
+		v, err := db.LookupFromHardwareAddrErr(hw)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: got err %v, want %v", tc.name, err, tc.err)
+		}
+		if tc.err == nil && v != "Vendor One" {
+			t.Errorf("%s: got %q, want 'Vendor One'", tc.name, v)
+		}
+	}
+
+	if _, err := db.LookupFromHardwareAddrErr(net.HardwareAddr{0xab, 0xcd, 0xef, 0x01}); !errors.Is(err, ErrInvalidMAC) {
+		t.Errorf("4-byte address: got err %v, want ErrInvalidMAC", err)
+	}
+}
+
+func TestLookupFromIP(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One"})
+	writeEntries(t, dir, map[string]int{"b827eb": 0})
+
+	db, err := Open(WithDir(dir), WithAutoUpdate(false))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	testCases := []struct {
+		ip  string
+		err error
+	}{
+		{"fe80::ba27:ebff:fe12:3456", nil},
+		{"2001:db8::ba27:ebff:fe12:3456", nil},
+		{"fe80::b827:ebff:fe12:3456", ErrNotFound}, // U/L bit not flipped back
+		{"fe80::1c2d:3e4f:5a6b:7c8d", ErrInvalidMAC},
+		{"192.0.2.1", ErrInvalidMAC},
+	}
+	for _, tc := range testCases {
+		v, err := db.LookupFromIPErr(net.ParseIP(tc.ip))
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: got err %v, want %v", tc.ip, err, tc.err)
+		}
+		if tc.err == nil && v != "Vendor One" {
+			t.Errorf("%s: got %q, want 'Vendor One'", tc.ip, v)
+		}
+	}
+}
+
+func TestLookupN_PrefixLengths(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Block Owner", "MA-M Owner", "MA-S Owner"})
+	writeEntries(t, dir, map[string]int{
+		"b827eb":    0,
+		"b827eb1":   1,
+		"b827eb123": 2,
+	})
+
+	db, err := Open(WithDir(dir), WithAutoUpdate(false))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	testCases := []struct {
+		in   string
+		bits int
+		want string
+		err  error
+	}{
+		{"b8:27:eb:xx:xx:xx", 24, "Block Owner", nil},
+		{"B8-27-EB-1x-xx-xx", 28, "MA-M Owner", nil},
+		{"b8:27:eb:12:3x:xx", 36, "MA-S Owner", nil},
+		{"b8:27:eb:2x:xx:xx", 28, "", ErrNotFound},
+		{"b8:27:eb:xx:xx:xx", 28, "", ErrInvalidMAC},
+	}
+	for _, tc := range testCases {
+		got, err := db.LookupN(tc.in, tc.bits)
+		if got != tc.want || !errors.Is(err, tc.err) {
+			t.Errorf("LookupN(%q, %d) = %q, %v; want %q, %v", tc.in, tc.bits, got, err, tc.want, tc.err)
+		}
+	}
+
+	if _, err := db.LookupN("b827eb", 32); err == nil {
+		t.Errorf("want error for unsupported prefix length")
+	}
+}
+
+func TestLookupAll(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One"})
+	writeEntries(t, dir, map[string]int{"abcdef": 0})
+
+	db, err := Open(WithDir(dir), WithAutoUpdate(false))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	got := db.LookupAll([]string{"ab:cd:ef:01:02:03", "bad", "AB-CD-EF-99-99-99", "bad", "000000"})
+	want := []Result{{"Vendor One", true}, {}, {"Vendor One", true}, {}, {}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestSpecialRanges(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"ICANN, IANA Department", "VMware, Inc."})
+	writeEntries(t, dir, map[string]int{"00005e": 0, "005056": 1})
+
+	db, err := Open(WithDir(dir), WithAutoUpdate(false))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{"00:00:5e:00:01:0a", "VRRP virtual router"},    // longer than the IANA block
+		{"00:00:5e:00:53:01", "ICANN, IANA Department"}, // not VRRP
+		{"00:50:56:01:02:03", "VMware, Inc."},           // registered prefix wins a tie
+		{"00:0c:29:01:02:03", "VMware virtual NIC"},     // missing from the dataset
+		{"02:42:ac:11:00:02", "Docker container"},       // locally administered
+		{"01:00:5e:7f:ff:fa", "IPv4 multicast"},         // 25-bit range
+		{"01:00:5e:80:00:01", ""},                       // outside it
+		{"ff:ff:ff:ff:ff:ff", "Broadcast"},
+		{"ffffff", ""},
+	}
+	for _, tc := range testCases {
+		if got, _ := db.Lookup(tc.in); got != tc.want {
+			t.Errorf("Lookup(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+		if got := db.LookupAll([]string{tc.in})[0].Vendor; got != tc.want {
+			t.Errorf("LookupAll(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	if rec, err := db.LookupRecord("0242.ac11.0002"); err != nil || rec.Prefix != "0242" || rec.Source != LayerSpecial {
+		t.Errorf("LookupRecord = %+v, %v", rec, err)
+	}
+
+	db, err = Open(WithDir(dir), WithAutoUpdate(false), WithSpecialRanges(false))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if v, err := db.LookupErr("00:00:5e:00:01:0a"); v != "ICANN, IANA Department" {
+		t.Errorf("without special ranges: got %q, %v", v, err)
+	}
+	if _, err := db.LookupErr("02:42:ac:11:00:02"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("without special ranges: got err %v, want ErrNotFound", err)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Raspberry Pi Foundation"})
+	writeEntries(t, dir, map[string]int{"b827eb": 0})
+	overrides := "# local names\nB8:27:EB,Lab Pi\nb8:27:eb:00:00:01,Door controller\n52-54-00,Test VM\n"
+	if err := os.WriteFile(filepath.Join(dir, OverridesName), []byte(overrides), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(WithDir(dir), WithAutoUpdate(false), WithOverrides(map[string]string{"b827eb12": "Rack 12"}))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{"b8:27:eb:99:00:01", "Lab Pi"},
+		{"b8:27:eb:00:00:01", "Door controller"},
+		{"b827.eb12.3456", "Rack 12"},
+		{"52:54:00:12:34:56", "Test VM"}, // over the special range
+	}
+	for _, tc := range testCases {
+		if got, _ := db.Lookup(tc.in); got != tc.want {
+			t.Errorf("Lookup(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+		if got := db.LookupAll([]string{tc.in})[0].Vendor; got != tc.want {
+			t.Errorf("LookupAll(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	if rec, err := db.LookupRecord("b8:27:eb:00:00:01"); err != nil || rec.Prefix != "b827eb000001" || rec.Source != LayerOverride {
+		t.Errorf("LookupRecord = %+v, %v", rec, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, OverridesName), []byte("b8:27:eb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(WithDir(dir), WithAutoUpdate(false)); !errors.Is(err, ErrCorruptDataset) {
+		t.Errorf("malformed %s: got err %v, want ErrCorruptDataset", OverridesName, err)
+	}
+}
+
+func TestWithPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"ICANN, IANA Department", "Microsoft Corporation"})
+	writeEntries(t, dir, map[string]int{"00005e": 0, "00155d": 1})
+	overrides := map[string]string{"00:15:5d:00:00:01": "Build agent"}
+
+	testCases := []struct {
+		layers []Layer
+		in     string
+		want   string
+		source Layer
+	}{
+		{nil, "00:00:5e:00:01:0a", "VRRP virtual router", LayerSpecial},
+		{nil, "00:15:5d:00:00:02", "Microsoft Corporation", LayerRegistry},
+		{nil, "00:15:5d:00:00:01", "Build agent", LayerOverride},
+		{[]Layer{LayerRegistry, LayerSpecial}, "00:00:5e:00:01:0a", "ICANN, IANA Department", LayerRegistry},
+		{[]Layer{LayerSpecial, LayerRegistry}, "00:15:5d:00:00:02", "Microsoft Hyper-V virtual NIC", LayerSpecial},
+		{[]Layer{LayerRegistry, LayerOverride}, "00:15:5d:00:00:01", "Microsoft Corporation", LayerRegistry},
+		{[]Layer{LayerSpecial}, "b8:27:eb:00:00:01", "", ""},
+	}
+	for _, tc := range testCases {
+		db, err := Open(WithDir(dir), WithAutoUpdate(false), WithOverrides(overrides), WithPrecedence(tc.layers...))
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		if got, _ := db.Lookup(tc.in); got != tc.want {
+			t.Errorf("%v: Lookup(%q) = %q, want %q", tc.layers, tc.in, got, tc.want)
+		}
+		if rec, _ := db.LookupRecord(tc.in); rec.Vendor != tc.want || rec.Source != tc.source {
+			t.Errorf("%v: LookupRecord(%q) = %+v, want %q from %q", tc.layers, tc.in, rec, tc.want, tc.source)
+		}
+	}
+
+	if _, err := Open(WithDir(dir), WithAutoUpdate(false), WithPrecedence("ieee")); err == nil {
+		t.Errorf("want error for unknown layer")
+	}
+}
+
+func TestSearchVendorIn(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One"})
+	writeEntries(t, dir, map[string]int{"abcdef": 0})
+
+	if got := SearchVendorIn(dir, "ab:cd:ef:00:00:01"); got != "Vendor One" {
+		t.Errorf("got %q, want Vendor One", got)
+	}
+	if got := SearchVendorIn(t.TempDir(), "ab:cd:ef:00:00:01"); got != "" {
+		t.Errorf("empty dir: got %q", got)
+	}
+}
+
+func TestResetDefault(t *testing.T) {
+	t.Cleanup(ResetDefault)
+	dirs := make([]string, 2)
+	for i, vendor := range []string{"First", "Second"} {
+		dirs[i] = t.TempDir()
+		if _, err := Build(strings.NewReader("Registry,Assignment,Organization Name,Organization Address\nMA-L,001122,"+vendor+",Addr\n"), dirs[i], nil); err != nil {
+			t.Fatalf("build: %v", err)
+		}
+	}
+	t.Setenv("PG_OUI_DATA_DIR", dirs[0])
+	ResetDefault()
+	if v := SearchVendor("00:11:22"); v != "First" {
+		t.Fatalf("got %q, want First", v)
+	}
+	t.Setenv("PG_OUI_DATA_DIR", dirs[1])
+	if v := SearchVendor("00:11:22"); v != "First" {
+		t.Errorf("before ResetDefault: got %q, want First", v)
+	}
+	ResetDefault()
+	if v := SearchVendor("00:11:22"); v != "Second" {
+		t.Errorf("after ResetDefault: got %q, want Second", v)
+	}
+}
@@ -0,0 +1,159 @@
+package pg_oui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Build input formats, selected with BuildOptions.Source.
+const (
+	SourceIEEE      = "ieee"      // IEEE registry CSV (the default)
+	SourceWireshark = "wireshark" // Wireshark manuf file
+	SourceNmap      = "nmap"      // nmap-mac-prefixes file
+)
+
+// Where update_data and the runtime auto-update fetch the non-IEEE sources.
+const (
+	WiresharkManufURL = "https://www.wireshark.org/download/automated/data/manuf"
+	NmapPrefixesURL   = "https://raw.githubusercontent.com/nmap/nmap/master/nmap-mac-prefixes"
+)
+
+// SourceURLs returns the files to download for a source format; registries
+// only applies to SourceIEEE (see RegistryURLs).
+func SourceURLs(source string, registries []string) ([]string, error) {
+	switch source {
+	case "", SourceIEEE:
+		return RegistryURLs(registries)
+	case SourceWireshark:
+		return []string{WiresharkManufURL}, nil
+	case SourceNmap:
+		return []string{NmapPrefixesURL}, nil
+	}
+	return nil, fmt.Errorf("unknown source %q (want ieee, wireshark or nmap)", source)
+}
+
+// mirrorURLs returns urls moved to a mirror at baseURL that serves the same
+// file names, e.g. https://mirror.example/oui/oui.csv for the MA-L registry
+// with baseURL https://mirror.example/oui.
+func mirrorURLs(baseURL string, urls []string) []string {
+	base := strings.TrimSuffix(baseURL, "/")
+	out := make([]string, len(urls))
+	for i, u := range urls {
+		out[i] = base + "/" + path.Base(u)
+	}
+	return out
+}
+
+// SourceAttribution credits the upstream publisher of a source format for
+// BuildOptions.Attribution: the IEEE Registration Authority with the
+// registries used, Wireshark or Nmap. urls are the files the data was
+// retrieved from.
+func SourceAttribution(source string, registries, urls []string) Attribution {
+	var name string
+	switch source {
+	case "", SourceIEEE:
+		if len(registries) == 0 {
+			registries = DefaultRegistries
+		}
+		regs := make([]string, len(registries))
+		for i, r := range registries {
+			regs[i] = strings.ToUpper(strings.TrimSpace(r))
+		}
+		name = "IEEE Registration Authority (" + strings.Join(regs, ", ") + ")"
+	case SourceWireshark:
+		name = "Wireshark manufacturer database"
+	case SourceNmap:
+		name = "Nmap MAC prefix database"
+	default:
+		name = source
+	}
+	return Attribution{Name: name, URLs: urls}
+}
+
+// manufRows parses Wireshark's manuf format, one
+// "prefix[/bits]<TAB>short name[<TAB>long name]" assignment per line, into
+// IEEE-style [registry, assignment, organization, address, short name]
+// records. Only 24, 28 and
+// 36-bit prefixes (MA-L, MA-M, MA-S) are kept.
+func manufRows(r io.Reader) func() ([]string, error) {
+	sc := bufio.NewScanner(r)
+	return func() ([]string, error) {
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || line[0] == '#' {
+				continue
+			}
+			f := strings.Split(line, "\t")
+			if len(f) < 2 {
+				continue
+			}
+			prefix, bits := f[0], 24
+			if i := strings.IndexByte(prefix, '/'); i >= 0 {
+				n, err := strconv.Atoi(prefix[i+1:])
+				if err != nil {
+					continue
+				}
+				prefix, bits = prefix[:i], n
+			}
+			var registry string
+			switch bits {
+			case 24:
+				registry = "MA-L"
+			case 28:
+				registry = "MA-M"
+			case 36:
+				registry = "MA-S"
+			default:
+				continue
+			}
+			hex := macCleaner.Replace(prefix)
+			if len(hex) < bits/4 || !allHex(hex[:bits/4]) {
+				continue
+			}
+			name := strings.TrimSpace(f[len(f)-1])
+			return []string{registry, strings.ToUpper(hex[:bits/4]), name, "", strings.TrimSpace(f[1])}, nil
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+}
+
+// nmapRows parses nmap-mac-prefixes, one "PREFIX Vendor Name" line per
+// assignment with a 6, 7 or 9 hex digit prefix, into IEEE-style records.
+func nmapRows(r io.Reader) func() ([]string, error) {
+	sc := bufio.NewScanner(r)
+	return func() ([]string, error) {
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || line[0] == '#' {
+				continue
+			}
+			prefix, name, ok := strings.Cut(line, " ")
+			if !ok || !allHex(prefix) {
+				continue
+			}
+			var registry string
+			switch len(prefix) {
+			case 6:
+				registry = "MA-L"
+			case 7:
+				registry = "MA-M"
+			case 9:
+				registry = "MA-S"
+			default:
+				continue
+			}
+			return []string{registry, strings.ToUpper(prefix), strings.TrimSpace(name)}, nil
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+}
@@ -0,0 +1,71 @@
+package pg_oui
+
+import "strconv"
+
+// specialRange is a well-known range of addresses that the IEEE registries
+// either do not cover (e.g. Docker's locally administered 02:42) or only
+// cover with an unhelpful owner (e.g. VRRP inside IANA's 00:00:5e).
+type specialRange struct {
+	prefix uint64 // the first bits bits of the range, right-aligned
+	bits   int
+	label  string
+}
+
+// specialRanges is ordered longest first. The 24-bit ranges duplicate IEEE
+// assignments and only matter for filtered datasets, which lack them.
+var specialRanges = newSpecialRanges([]struct {
+	hex   string
+	bits  int
+	label string
+}{
+	{"ffffffffffff", 48, "Broadcast"},
+	{"0180c200000", 44, "IEEE 802.1 link-local (STP, LACP, LLDP)"},
+	{"00005e0001", 40, "VRRP virtual router"},
+	{"00005e0002", 40, "VRRP virtual router (IPv6)"},
+	{"00000c07ac", 40, "HSRP virtual router"},
+	{"00000c9ff", 36, "HSRPv2 virtual router"},
+	{"000573a00", 36, "HSRP virtual router (IPv6)"},
+	{"01005e0", 25, "IPv4 multicast"},
+	{"005056", 24, "VMware virtual NIC"},
+	{"000c29", 24, "VMware virtual NIC"},
+	{"000569", 24, "VMware virtual NIC"},
+	{"001c14", 24, "VMware virtual NIC"},
+	{"00155d", 24, "Microsoft Hyper-V virtual NIC"},
+	{"525400", 24, "QEMU/KVM virtual NIC"},
+	{"00163e", 24, "Xen virtual NIC"},
+	{"080027", 24, "VirtualBox virtual NIC"},
+	{"0a0027", 24, "VirtualBox host-only adapter"},
+	{"001c42", 24, "Parallels virtual NIC"},
+	{"0242", 16, "Docker container"},
+	{"3333", 16, "IPv6 multicast"},
+})
+
+func newSpecialRanges(list []struct {
+	hex   string
+	bits  int
+	label string
+}) []specialRange {
+	out := make([]specialRange, len(list))
+	for i, r := range list {
+		v, err := strconv.ParseUint(r.hex, 16, 64)
+		if err != nil || 4*len(r.hex) < r.bits {
+			panic("pg_oui: bad special range " + r.hex)
+		}
+		out[i] = specialRange{prefix: v >> (4*len(r.hex) - r.bits), bits: r.bits, label: r.label}
+	}
+	return out
+}
+
+// matchSpecial returns the longest special range longer than minBits that
+// contains the n-digit prefix v of a MAC.
+func matchSpecial(v uint64, n, minBits int) (specialRange, bool) {
+	for _, r := range specialRanges {
+		if r.bits <= minBits {
+			break
+		}
+		if 4*n >= r.bits && v>>(4*n-r.bits) == r.prefix {
+			return r, true
+		}
+	}
+	return specialRange{}, false
+}
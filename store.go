@@ -0,0 +1,279 @@
+package pg_oui
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const (
+	// CurrentLink is the symlink in the data dir that points at the active
+	// content-addressed dataset, see StoreDataset.
+	CurrentLink = "current"
+	// DatasetsDir holds the content-addressed datasets in the data dir.
+	DatasetsDir = "datasets"
+)
+
+// datasetFiles are the files StoreDataset moves and hashes.
+var datasetFiles = []string{BinaryName, defaultEntries, defaultVendors, defaultIndex}
+
+// StoreDataset moves the dataset built in src into dir/datasets/<id>, where
+// id is the SHA-256 of its files, and atomically points dir/current at it.
+// A dataset already stored under the same id is reused, so profiles that
+// resolve to identical content share one copy. Open reads dir/current when
+// dir itself holds no dataset; DBs that already loaded one are unaffected by
+// the switch.
+func StoreDataset(dir, src string) (string, error) {
+	h := sha256.New()
+	var names []string
+	for _, n := range datasetFiles {
+		b, err := os.ReadFile(filepath.Join(src, n))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("hash dataset: %w", err)
+		}
+		fmt.Fprintf(h, "%s %d\n", n, len(b))
+		h.Write(b)
+		names = append(names, n)
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("store dataset: %w in %q", ErrDatasetNotFound, src)
+	}
+	id := hex.EncodeToString(h.Sum(nil))
+	// The build time would defeat dedup, so the info file is not hashed.
+	if _, err := os.Stat(filepath.Join(src, DatasetInfoName)); err == nil {
+		names = append(names, DatasetInfoName)
+	}
+
+	dst := filepath.Join(dir, DatasetsDir, id)
+	if _, err := os.Stat(dst); errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return "", fmt.Errorf("store dataset: %w", err)
+		}
+		tmp, err := os.MkdirTemp(filepath.Dir(dst), ".tmp-")
+		if err != nil {
+			return "", fmt.Errorf("store dataset: %w", err)
+		}
+		for _, n := range names {
+			if err := os.Rename(filepath.Join(src, n), filepath.Join(tmp, n)); err != nil {
+				os.RemoveAll(tmp)
+				return "", fmt.Errorf("store dataset: %w", err)
+			}
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			os.RemoveAll(tmp)
+			// Another process may have stored the same dataset meanwhile.
+			if _, serr := os.Stat(dst); serr != nil {
+				return "", fmt.Errorf("store dataset: %w", err)
+			}
+		}
+	}
+	return id, UseDataset(dir, id)
+}
+
+// UseDataset atomically points dir/current at the stored dataset id, e.g.
+// to roll back to an earlier one listed by StoredDatasets.
+func UseDataset(dir, id string) error {
+	target := filepath.Join(DatasetsDir, id)
+	if _, err := os.Stat(filepath.Join(dir, target)); err != nil {
+		return fmt.Errorf("use dataset: %w", err)
+	}
+	tmp := filepath.Join(dir, CurrentLink+".tmp")
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("use dataset: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, CurrentLink)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("use dataset: %w", err)
+	}
+	return nil
+}
+
+// replacingName marks a data dir whose files replaceDataset is renaming,
+// so load does not accept a mix of old and new files.
+const replacingName = ".replacing"
+
+// replaceDataset moves the dataset files in src into dir, one rename each,
+// and removes those of an earlier dataset in dir that src lacks (e.g. oui.bin
+// when src holds the three-file layout). Concurrent writers must hold
+// LockDir.
+func replaceDataset(dir, src string) error {
+	marker := filepath.Join(dir, replacingName)
+	if err := os.WriteFile(marker, nil, 0o644); err != nil {
+		return err
+	}
+	defer os.Remove(marker)
+	names := append([]string{DatasetInfoName}, datasetFiles...)
+	var stale []string
+	for _, n := range names {
+		err := os.Rename(filepath.Join(src, n), filepath.Join(dir, n))
+		if errors.Is(err, fs.ErrNotExist) {
+			stale = append(stale, n)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, n := range stale {
+		if err := os.Remove(filepath.Join(dir, n)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// LockName is the lock file LockDir creates in a data dir.
+const LockName = ".lock"
+
+// ErrLocked is returned by LockDir when another process holds the lock.
+var ErrLocked = errors.New("data dir is locked")
+
+// LockDir takes the lock of the data dir, so that processes updating the
+// same dir take turns, and returns the function releasing it. A lock left
+// behind by a process that crashed has to be removed by hand; its file
+// holds the process ID and the time it was taken.
+func LockDir(dir string) (unlock func(), err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("lock data dir: %w", err)
+	}
+	path := filepath.Join(dir, LockName)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		b, _ := os.ReadFile(path)
+		return nil, fmt.Errorf("%w: %s exists (%s)", ErrLocked, path, bytes.TrimSpace(b))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock data dir: %w", err)
+	}
+	fmt.Fprintf(f, "pid %d since %s\n", os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("lock data dir: %w", err)
+	}
+	return func() { os.Remove(path) }, nil
+}
+
+// StoredDatasets returns the ids of the datasets stored in dir, sorted, and
+// the id dir/current points at ("" if none).
+func StoredDatasets(dir string) (ids []string, current string, err error) {
+	ents, err := os.ReadDir(filepath.Join(dir, DatasetsDir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, "", fmt.Errorf("list datasets: %w", err)
+	}
+	for _, e := range ents {
+		if e.IsDir() && e.Name()[0] != '.' {
+			ids = append(ids, e.Name())
+		}
+	}
+	slices.Sort(ids)
+	if target, err := os.Readlink(filepath.Join(dir, CurrentLink)); err == nil {
+		current = filepath.Base(target)
+	}
+	return ids, current, nil
+}
+
+// dataDirFS returns the FS holding the dataset in dir, or dir/current, and
+// whether there is one.
+func dataDirFS(dir string, cfg *openCfg) (fs.FS, bool) {
+	for _, d := range []string{dir, filepath.Join(dir, CurrentLink)} {
+		if fsys := os.DirFS(d); hasDataset(fsys, cfg) {
+			return fsys, true
+		}
+	}
+	return nil, false
+}
+
+// migrateLegacy moves a dataset kept directly in dir, or for the default
+// data dir one in the current directory where older versions looked, into
+// the content-addressed layout. Legacy indexes without a footer are
+// rewritten and a missing DatasetInfoName is filled in from the files. The
+// originals in dir are removed once stored; those in the current directory
+// are left alone. It does nothing once dir/current exists or when WithFiles
+// renamed the data files.
+func migrateLegacy(dir string, cfg *openCfg) error {
+	if cfg.entriesName != defaultEntries || cfg.vendorsName != defaultVendors || cfg.indexName != defaultIndex {
+		return nil
+	}
+	if _, err := os.Lstat(filepath.Join(dir, CurrentLink)); err == nil {
+		return nil
+	}
+	srcs := []string{dir}
+	if cfg.dir == "" && dir != "." {
+		srcs = append(srcs, ".")
+	}
+	for _, src := range srcs {
+		if !hasDataset(os.DirFS(src), cfg) {
+			continue
+		}
+		if err := migrateDataset(dir, src, cfg); err != nil {
+			return fmt.Errorf("migrate dataset in %s: %w", src, err)
+		}
+		if src != dir {
+			return nil
+		}
+		for _, n := range append(datasetFiles, DatasetInfoName) {
+			os.Remove(filepath.Join(dir, n))
+		}
+		return nil
+	}
+	return nil
+}
+
+// migrateDataset copies the dataset in src into a new build dir below dir,
+// upgrading it to the current format, and stores it.
+func migrateDataset(dir, src string, cfg *openCfg) error {
+	d, err := load(cfg, os.DirFS(src), "dir")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(dir, ".migrate-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	files := []string{BinaryName}
+	if _, err := os.Stat(filepath.Join(src, BinaryName)); err != nil {
+		files = []string{defaultEntries, defaultVendors}
+		if err := writeFile(filepath.Join(tmp, defaultIndex), func(w *bufio.Writer) error { return writeIndex(w, []byte(d.vendors)) }); err != nil {
+			return err
+		}
+	}
+	for _, n := range append(files, DatasetInfoName) {
+		b, err := os.ReadFile(filepath.Join(src, n))
+		if n == DatasetInfoName && errors.Is(err, fs.ErrNotExist) {
+			st, err := os.Stat(filepath.Join(src, files[0]))
+			if err != nil {
+				return err
+			}
+			info := DatasetInfo{BuiltAt: st.ModTime().UTC().Truncate(time.Second), Source: "migrated from " + src, Entries: d.entries.len(), Vendors: max(len(d.offsets)-1, 0)}
+			if b, err = json.MarshalIndent(info, "", "  "); err != nil {
+				return err
+			}
+			b = append(b, '\n')
+		} else if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(tmp, n), b, 0o644); err != nil {
+			return err
+		}
+	}
+	_, err = StoreDataset(dir, tmp)
+	return err
+}
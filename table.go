@@ -0,0 +1,241 @@
+package pg_oui
+
+import (
+	"cmp"
+	"iter"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// prefixTable maps prefixes to vendor IDs. Prefixes of 6 to 9 lower-case hex
+// digits are stored as integers in one sorted array per length and found by
+// binary search, which takes about 8 bytes per entry instead of the ~60 a
+// map[string]int needs. See BenchmarkOpen and BenchmarkLookup.
+type prefixTable struct {
+	short [3]sortedKeys[uint32] // 6, 7 and 8 digits
+	long  sortedKeys[uint64]    // 9 digits
+	odd   map[string]int        // any other key, kept so validation can report it
+}
+
+// bucketBits is how many leading key bits index sortedKeys.start, so a
+// search only covers the keys sharing them.
+const bucketBits = 10
+
+type sortedKeys[K uint32 | uint64] struct {
+	keys  []K
+	ids   []int32
+	start []int32 // start[b] is the first key whose leading bits are >= b
+	shift uint    // key >> shift gives its leading bucketBits bits
+}
+
+func newSortedKeys[K uint32 | uint64](keys []K, ids []int32, digits int) sortedKeys[K] {
+	s := sortedKeys[K]{keys: keys, ids: ids, shift: uint(4*digits - bucketBits)}
+	if len(keys) == 0 {
+		return s
+	}
+	s.start = make([]int32, 1<<bucketBits+1)
+	i := 0
+	for b := range s.start {
+		for i < len(keys) && int(keys[i]>>s.shift) < b {
+			i++
+		}
+		s.start[b] = int32(i)
+	}
+	return s
+}
+
+func (s *sortedKeys[K]) get(k K) (int, bool) {
+	if len(s.keys) == 0 {
+		return 0, false
+	}
+	b := k >> s.shift
+	lo, hi := int(s.start[b]), int(s.start[b+1])
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		if s.keys[m] < k {
+			lo = m + 1
+		} else {
+			hi = m
+		}
+	}
+	if lo < int(s.start[b+1]) && s.keys[lo] == k {
+		return int(s.ids[lo]), true
+	}
+	return 0, false
+}
+
+// newPrefixTable builds the table for the prefix -> vendor ID map m.
+func newPrefixTable(m map[string]int) prefixTable {
+	type pair struct {
+		k  uint64
+		id int32
+	}
+	var byLen [4][]pair
+	var t prefixTable
+	for key, id := range m {
+		v, ok := parseKey(key)
+		if !ok || len(key) < 6 || id < math.MinInt32 || id > math.MaxInt32 {
+			if t.odd == nil {
+				t.odd = make(map[string]int)
+			}
+			t.odd[key] = id
+			continue
+		}
+		byLen[len(key)-6] = append(byLen[len(key)-6], pair{v, int32(id)})
+	}
+	for n, ps := range byLen {
+		slices.SortFunc(ps, func(a, b pair) int { return cmp.Compare(a.k, b.k) })
+		ids := make([]int32, len(ps))
+		for i, p := range ps {
+			ids[i] = p.id
+		}
+		if n == 3 {
+			keys := make([]uint64, len(ps))
+			for i, p := range ps {
+				keys[i] = p.k
+			}
+			t.long = newSortedKeys(keys, ids, 9)
+			continue
+		}
+		keys := make([]uint32, len(ps))
+		for i, p := range ps {
+			keys[i] = uint32(p.k)
+		}
+		t.short[n] = newSortedKeys(keys, ids, n+6)
+	}
+	return t
+}
+
+// parseKey parses up to 9 lower-case hex digits.
+func parseKey(s string) (uint64, bool) {
+	if len(s) > 9 {
+		return 0, false
+	}
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c -= 'a' - 10
+		default:
+			return 0, false
+		}
+		v = v<<4 | uint64(c)
+	}
+	return v, true
+}
+
+func formatKey(v uint64, n int) string {
+	s := strconv.FormatUint(v, 16)
+	return strings.Repeat("0", n-len(s)) + s
+}
+
+// find returns the ID of the n-digit prefix v.
+func (t *prefixTable) find(v uint64, n int) (int, bool) {
+	if n == 9 {
+		return t.long.get(v)
+	}
+	return t.short[n-6].get(uint32(v))
+}
+
+// get returns the vendor ID of exactly key.
+func (t *prefixTable) get(key string) (int, bool) {
+	if v, ok := parseKey(key); ok && len(key) >= 6 {
+		return t.find(v, len(key))
+	}
+	id, ok := t.odd[key]
+	return id, ok
+}
+
+// match returns the longest prefix of key in the table and its vendor ID.
+func (t *prefixTable) match(key string) (string, int, bool) {
+	n := min(len(key), 9)
+	v, ok := parseKey(key[:n])
+	if !ok {
+		return "", 0, false
+	}
+	if n, id, ok := t.matchValue(v, n); ok {
+		return key[:n], id, true
+	}
+	return "", 0, false
+}
+
+// matchValue returns the length and vendor ID of the longest prefix of the
+// n-digit prefix v in the table.
+func (t *prefixTable) matchValue(v uint64, n int) (int, int, bool) {
+	for ; n >= 6; n-- {
+		if id, ok := t.find(v, n); ok {
+			return n, id, true
+		}
+		v >>= 4
+	}
+	return 0, 0, false
+}
+
+// split reports whether the table holds a prefix longer than the n-digit
+// prefix v that starts with it, e.g. an MA-S block inside an MA-L block.
+func (t *prefixTable) split(v uint64, n int) bool {
+	for m := n + 1; m <= 9; m++ {
+		lo := v << (4 * (m - n))
+		hi := lo | (1<<(4*(m-n)) - 1)
+		if m == 9 {
+			if t.long.within(lo, hi) {
+				return true
+			}
+		} else if t.short[m-6].within(uint32(lo), uint32(hi)) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether s holds a key in [lo, hi].
+func (s *sortedKeys[K]) within(lo, hi K) bool {
+	i, _ := slices.BinarySearch(s.keys, lo)
+	return i < len(s.keys) && s.keys[i] <= hi
+}
+
+func (t *prefixTable) len() int {
+	n := len(t.long.keys) + len(t.odd)
+	for _, s := range t.short {
+		n += len(s.keys)
+	}
+	return n
+}
+
+// all iterates over every prefix and its vendor ID in no particular order.
+func (t *prefixTable) all() iter.Seq2[string, int] {
+	return func(yield func(string, int) bool) {
+		for i, s := range t.short {
+			for j, k := range s.keys {
+				if !yield(formatKey(uint64(k), i+6), int(s.ids[j])) {
+					return
+				}
+			}
+		}
+		for j, k := range t.long.keys {
+			if !yield(formatKey(k, 9), int(t.long.ids[j])) {
+				return
+			}
+		}
+		for k, id := range t.odd {
+			if !yield(k, id) {
+				return
+			}
+		}
+	}
+}
+
+// sorted returns every prefix in ascending order.
+func (t *prefixTable) sorted() []string {
+	keys := make([]string, 0, t.len())
+	for k := range t.all() {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
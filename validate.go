@@ -0,0 +1,109 @@
+package pg_oui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationLevel selects how much Open checks the loaded dataset.
+type ValidationLevel int
+
+const (
+	// ValidateNone only performs the parsing Open always does.
+	ValidateNone ValidationLevel = iota
+	// ValidateFast checks counts and that index offsets are monotonic and
+	// within the vendors file.
+	ValidateFast
+	// ValidateThorough additionally checks every entry's OUI and vendor ID,
+	// and reports duplicate OUIs in the entries file.
+	ValidateThorough
+)
+
+// WithValidation makes Open validate the dataset at the given level and fail
+// with a *ValidationError if any problem is found.
+func WithValidation(level ValidationLevel) Option {
+	return func(c *openCfg) { c.validation = level }
+}
+
+// ValidationError lists the problems found by Validate.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	const show = 5
+	msg := strings.Join(e.Problems[:min(len(e.Problems), show)], "; ")
+	if n := len(e.Problems) - show; n > 0 {
+		msg += fmt.Sprintf("; and %d more", n)
+	}
+	return "invalid dataset: " + msg
+}
+
+// Unwrap makes errors.Is(err, ErrCorruptDataset) report validation failures.
+func (e *ValidationError) Unwrap() error { return ErrCorruptDataset }
+
+// Validate checks the loaded dataset at the given level. It returns nil or a
+// *ValidationError.
+func (db *DB) Validate(level ValidationLevel) error {
+	return db.cur.Load().validate(level)
+}
+
+func (d *dataset) validate(level ValidationLevel) error {
+	if level <= ValidateNone {
+		return nil
+	}
+	var problems []string
+	add := func(format string, args ...any) { problems = append(problems, fmt.Sprintf(format, args...)) }
+
+	if d.entries.len() == 0 {
+		add("no entries")
+	}
+	if len(d.offsets) < 2 {
+		add("index has no vendors")
+	}
+	if len(d.offsets) > 0 && d.offsets[0] != 0 {
+		add("first index offset is %d, want 0", d.offsets[0])
+	}
+	for i := 1; i < len(d.offsets); i++ {
+		if d.offsets[i] < d.offsets[i-1] {
+			add("index offset %d (%d) is before offset %d (%d)", i, d.offsets[i], i-1, d.offsets[i-1])
+		}
+	}
+	if n := len(d.offsets); n > 0 && d.offsets[n-1] != int64(len(d.vendors)) {
+		add("last index offset %d does not match vendors size %d", d.offsets[n-1], len(d.vendors))
+	}
+
+	if level >= ValidateThorough {
+		nv := len(d.offsets) - 1
+		for _, oui := range d.entries.sorted() {
+			id, _ := d.entries.get(oui)
+			if n := len(oui); n != 6 && n != 7 && n != 9 || strings.ToLower(oui) != oui || !allHex(oui) {
+				add("entry %q: prefix is not 6, 7 or 9 lower-case hex digits", oui)
+			}
+			if id < 0 || id >= nv {
+				add("entry %q: vendor ID %d out of range [0, %d)", oui, id, nv)
+				continue
+			}
+			if v, err := d.vendorByID(id); err != nil || v == "" {
+				add("entry %q: vendor ID %d has no name", oui, id)
+			}
+		}
+		for _, oui := range d.dups {
+			add("entry %q: duplicate OUI", oui)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func allHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isHex(s[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package pg_oui
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithValidation_Levels(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One", "Vendor Two"})
+	writeEntries(t, dir, map[string]int{
+		"abcdef": 0,
+		"abcd12": 7, // no such vendor
+	})
+
+	if _, err := Open(WithDir(dir), WithAutoUpdate(false)); err != nil {
+		t.Fatalf("open without validation: %v", err)
+	}
+	if _, err := Open(WithDir(dir), WithAutoUpdate(false), WithValidation(ValidateFast)); err != nil {
+		t.Fatalf("fast validation should not check entries: %v", err)
+	}
+
+	_, err := Open(WithDir(dir), WithAutoUpdate(false), WithValidation(ValidateThorough))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("want *ValidationError, got %v", err)
+	}
+	if len(verr.Problems) != 1 {
+		t.Fatalf("want 1 problem, got %v", verr.Problems)
+	}
+}
+
+func TestOpen_DatasetErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Open(WithDir(dir), WithAutoUpdate(false)); !errors.Is(err, ErrDatasetNotFound) {
+		t.Fatalf("empty dir: want ErrDatasetNotFound, got %v", err)
+	}
+
+	writeVendors(t, dir, []string{"Vendor One"})
+	writeEntries(t, dir, map[string]int{"abcdef": 3})
+	if _, err := Open(WithDir(dir), WithAutoUpdate(false), WithValidation(ValidateThorough)); !errors.Is(err, ErrCorruptDataset) {
+		t.Fatalf("bad vendor ID: want ErrCorruptDataset, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vendors.index"), []byte{1, 2, 3}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(WithDir(dir), WithAutoUpdate(false)); !errors.Is(err, ErrCorruptDataset) || !errors.Is(err, ErrIndexCorrupt) {
+		t.Fatalf("truncated index: want ErrIndexCorrupt, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vendors.index"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(WithDir(dir), WithAutoUpdate(false)); !errors.Is(err, ErrEmptyIndex) || !errors.Is(err, ErrCorruptDataset) {
+		t.Fatalf("empty index: want ErrEmptyIndex, got %v", err)
+	}
+}
+
+func TestValidate_FastDetectsTruncatedVendors(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One", "Vendor Two"})
+	writeEntries(t, dir, map[string]int{"abcdef": 0})
+	writeVendorsOnly(t, dir, "Vendor One\n") // legacy index now points past the end
+
+	_, err := Open(WithDir(dir), WithAutoUpdate(false), WithValidation(ValidateFast))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("want *ValidationError, got %v", err)
+	}
+}
+
+func TestWithDuplicatePolicy(t *testing.T) {
+	dir := t.TempDir()
+	writeVendors(t, dir, []string{"Vendor One", "Vendor Two"})
+	if err := os.WriteFile(filepath.Join(dir, "entries"), []byte("abcdef,0\nabcdef,1\n"), 0o644); err != nil {
+		t.Fatalf("write entries: %v", err)
+	}
+
+	testCases := []struct {
+		policy DuplicatePolicy
+		want   string
+	}{
+		{DuplicateLast, "Vendor Two"},
+		{DuplicateFirst, "Vendor One"},
+	}
+	for _, tc := range testCases {
+		db, err := Open(WithDir(dir), WithAutoUpdate(false), WithDuplicatePolicy(tc.policy))
+		if err != nil {
+			t.Fatalf("policy %d: open: %v", tc.policy, err)
+		}
+		if v, _ := db.Lookup("abcdef"); v != tc.want {
+			t.Errorf("policy %d: got %q, want %q", tc.policy, v, tc.want)
+		}
+		var verr *ValidationError
+		if err := db.Validate(ValidateThorough); !errors.As(err, &verr) || len(verr.Problems) != 1 {
+			t.Errorf("policy %d: want duplicate reported by Validate, got %v", tc.policy, err)
+		}
+	}
+
+	if _, err := Open(WithDir(dir), WithAutoUpdate(false), WithDuplicatePolicy(DuplicateError)); err == nil {
+		t.Fatalf("want error for duplicate OUIs")
+	}
+}